@@ -3,6 +3,12 @@ package concrete
 
 import (
 	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"regexp"
 	"strings"
 )
 
@@ -12,21 +18,61 @@ type Types []string
 // Instances holds all the concrete instances which are to create
 type Instances struct {
 	Instance []Types
+	// Names holds the name of each instance. If the name is empty,
+	// the name is derived from the concrete types.
+	Names []string
+	// Imports holds the packages which are referenced by fully qualified types
+	Imports []Import
 }
 
-// New creates a new instance
+// Name returns the name of the instance with the given index.
+func (i *Instances) Name(index int) string {
+	if index < len(i.Names) {
+		return i.Names[index]
+	}
+	return ""
+}
+
+var instanceName = regexp.MustCompile(`^\s*([\pL_][\pL\pN_]*)\s*=`)
+
+// New creates a new instance.
+// The instances are separated by a semicolon, the types of an instance by a comma.
+// Every type can be an arbitrary Go type expression like map[string]int or func(a, b int) bool.
+// Commas and semicolons inside of brackets, braces or parentheses do not separate types.
+// A type from an other package can be given by its full import path, e.g.
+// github.com/acme/app/models.User. Such a type is replaced by a type qualified
+// by the package name and the package is added to the imports.
+// An instance can be named by putting the name and an equal sign in front of the
+// types, e.g. "Users=string,*models.User".
 func New(types string) (*Instances, error) {
 	con := Instances{}
-	inst := strings.Split(types, ";")
+	inst, err := split(types, 0, ';')
+	if err != nil {
+		return nil, err
+	}
 	for _, i := range inst {
-		t := strings.Split(i, ",")
+		name := ""
+		if m := instanceName.FindStringSubmatchIndex(i.text); m != nil {
+			name = i.text[m[2]:m[3]]
+			if token.Lookup(name).IsKeyword() {
+				return nil, fmt.Errorf("column %d: the instance name %s is a keyword", i.offset+m[2]+1, name)
+			}
+			if name == "_" {
+				return nil, fmt.Errorf("column %d: the blank identifier can not be an instance name", i.offset+m[2]+1)
+			}
+			i = part{i.text[m[1]:], i.offset + m[1]}
+		}
+		t, err := split(i.text, i.offset, ',')
+		if err != nil {
+			return nil, err
+		}
 		var ty Types
 		for _, t := range t {
-			t = strings.TrimSpace(t)
-			if t == "" {
-				return nil, errors.New("empty concrete type")
+			typ, err := con.parseType(t)
+			if err != nil {
+				return nil, err
 			}
-			ty = append(ty, t)
+			ty = append(ty, typ)
 		}
 		if len(ty) == 0 {
 			return nil, errors.New("no concrete type given")
@@ -37,6 +83,238 @@ func New(types string) (*Instances, error) {
 			}
 		}
 		con.Instance = append(con.Instance, ty)
+		con.Names = append(con.Names, name)
+	}
+	err = con.checkNames()
+	if err != nil {
+		return nil, err
 	}
 	return &con, nil
 }
+
+// checkNames checks that all instances are creating different names
+func (i *Instances) checkNames() error {
+	names := map[string]int{}
+	for index, types := range i.Instance {
+		name := i.Name(index)
+		if name == "" {
+			for _, t := range types {
+				name += Suffix(t)
+			}
+		}
+		if other, ok := names[name]; ok {
+			return fmt.Errorf("instance %d and instance %d are both named %s", other+1, index+1, name)
+		}
+		names[name] = index
+	}
+	return nil
+}
+
+// part is a part of the string given to New
+type part struct {
+	text string
+	// the offset of the text in the string given to New
+	offset int
+}
+
+// split splits the given string at all occurrences of sep
+// which are not enclosed in brackets or string literals.
+func split(s string, offset int, sep rune) ([]part, error) {
+	var parts []part
+	var stack []rune
+	var quote rune
+	start := 0
+	for i, r := range s {
+		if quote != 0 {
+			if r == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch r {
+		case '"', '`':
+			quote = r
+		case '(':
+			stack = append(stack, ')')
+		case '[':
+			stack = append(stack, ']')
+		case '{':
+			stack = append(stack, '}')
+		case ')', ']', '}':
+			if len(stack) == 0 || stack[len(stack)-1] != r {
+				return nil, fmt.Errorf("column %d: unexpected %q", offset+i+1, r)
+			}
+			stack = stack[:len(stack)-1]
+		case sep:
+			if len(stack) == 0 {
+				parts = append(parts, part{s[start:i], offset + start})
+				start = i + 1
+			}
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("column %d: string literal not terminated", offset+len(s)+1)
+	}
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("column %d: missing %q", offset+len(s)+1, stack[len(stack)-1])
+	}
+	return append(parts, part{s[start:], offset + start}), nil
+}
+
+// parseType checks that the given part is a valid Go type
+// and returns the type with surrounding white space removed.
+func (i *Instances) parseType(p part) (string, error) {
+	t := strings.TrimSpace(p.text)
+	if t == "" {
+		return "", fmt.Errorf("column %d: empty concrete type", p.offset+1)
+	}
+	col := p.offset + strings.Index(p.text, t) + 1
+
+	q, shifts := i.qualify(t)
+	expr, err := parser.ParseExpr(q)
+	if err != nil {
+		if list, ok := err.(scanner.ErrorList); ok && len(list) > 0 {
+			return "", fmt.Errorf("column %d: invalid type %s: %s", col+origPos(list[0].Pos.Column-1, shifts), t, list[0].Msg)
+		}
+		return "", fmt.Errorf("column %d: invalid type %s: %v", col, t, err)
+	}
+	if !isType(expr) {
+		return "", fmt.Errorf("column %d: %s is not a type", col, t)
+	}
+	return q, nil
+}
+
+func isType(expr ast.Expr) bool {
+	switch e := expr.(type) {
+	case *ast.ArrayType:
+		// the length of an array type can only be omitted in a composite literal
+		_, ellipsis := e.Len.(*ast.Ellipsis)
+		return !ellipsis
+	case *ast.Ident, *ast.MapType, *ast.ChanType, *ast.FuncType, *ast.StructType, *ast.InterfaceType:
+		return true
+	case *ast.SelectorExpr:
+		_, ok := e.X.(*ast.Ident)
+		return ok
+	case *ast.StarExpr:
+		return isType(e.X)
+	case *ast.ParenExpr:
+		return isType(e.X)
+	}
+	return false
+}
+
+// Suffix returns the suffix which is appended to the name of a renamed
+// declaration if it depends on the given type, e.g. "Int64" for int64
+// or "MapStringInt" for map[string]int.
+func Suffix(typ string) string {
+	expr, err := parser.ParseExpr(typ)
+	if err != nil {
+		return simpleSuffix(typ)
+	}
+	return suffixOf(expr)
+}
+
+// simpleSuffix creates the suffix of simple types like int64, *int64 or pack.Type
+func simpleSuffix(typ string) string {
+	typ = strings.Replace(typ, "*", "P", -1)
+	typ = strings.Replace(typ, ".", "", -1)
+	return strings.Title(typ)
+}
+
+func suffixOf(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		return strings.Title(e.Name)
+	case *ast.SelectorExpr:
+		return simpleSuffix(exprString(e))
+	case *ast.StarExpr:
+		if isSimple(e) {
+			return simpleSuffix(exprString(e))
+		}
+		return "P" + suffixOf(e.X)
+	case *ast.ParenExpr:
+		return suffixOf(e.X)
+	case *ast.BasicLit:
+		return e.Value
+	case *ast.Ellipsis:
+		return "Variadic" + suffixOf(e.Elt)
+	case *ast.ArrayType:
+		if e.Len == nil {
+			return "Slice" + suffixOf(e.Elt)
+		}
+		return "Array" + suffixOf(e.Len) + suffixOf(e.Elt)
+	case *ast.MapType:
+		return "Map" + suffixOf(e.Key) + suffixOf(e.Value)
+	case *ast.ChanType:
+		switch e.Dir {
+		case ast.SEND:
+			return "SendChan" + suffixOf(e.Value)
+		case ast.RECV:
+			return "RecvChan" + suffixOf(e.Value)
+		}
+		return "Chan" + suffixOf(e.Value)
+	case *ast.FuncType:
+		return "Func" + fieldsSuffix(e.Params, false) + fieldsSuffix(e.Results, false)
+	case *ast.StructType:
+		return "Struct" + fieldsSuffix(e.Fields, true)
+	case *ast.InterfaceType:
+		s := "Interface"
+		for _, m := range e.Methods.List {
+			if len(m.Names) == 0 {
+				s += suffixOf(m.Type)
+			}
+			for _, n := range m.Names {
+				s += strings.Title(n.Name)
+			}
+		}
+		return s
+	}
+	return simpleSuffix(exprString(expr))
+}
+
+// fieldsSuffix creates the suffix of a field list. Every type is
+// repeated for each of its names. If withNames is set, also the names are added.
+func fieldsSuffix(fields *ast.FieldList, withNames bool) string {
+	if fields == nil {
+		return ""
+	}
+	s := ""
+	for _, f := range fields.List {
+		typ := suffixOf(f.Type)
+		if len(f.Names) == 0 {
+			s += typ
+		}
+		for _, n := range f.Names {
+			if withNames {
+				s += strings.Title(n.Name)
+			}
+			s += typ
+		}
+	}
+	return s
+}
+
+// isSimple checks if the expression consists only of identifiers, dots and stars
+func isSimple(expr ast.Expr) bool {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		return true
+	case *ast.SelectorExpr:
+		return isSimple(e.X)
+	case *ast.StarExpr:
+		return isSimple(e.X)
+	}
+	return false
+}
+
+func exprString(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		return e.Name
+	case *ast.SelectorExpr:
+		return exprString(e.X) + "." + e.Sel.Name
+	case *ast.StarExpr:
+		return "*" + exprString(e.X)
+	}
+	return ""
+}
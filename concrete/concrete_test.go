@@ -1,6 +1,7 @@
 package concrete
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -48,3 +49,91 @@ func TestEmpty(t *testing.T) {
 	_, err := New("")
 	assert.Error(t, err)
 }
+
+func TestTypeExpressions(t *testing.T) {
+	c, err := New("map[string]int, func(a, b int) bool; struct{X, Y int}, chan<- []byte")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(c.Instance))
+	assert.Equal(t, Types{"map[string]int", "func(a, b int) bool"}, c.Instance[0])
+	assert.Equal(t, Types{"struct{X, Y int}", "chan<- []byte"}, c.Instance[1])
+}
+
+func TestStructWithSemicolon(t *testing.T) {
+	c, err := New("struct{X int; Y string `json:\"a,b;c\"`}")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(c.Instance))
+	assert.Equal(t, 1, len(c.Instance[0]))
+}
+
+func TestInvalidTypes(t *testing.T) {
+	data := []struct {
+		types, err string
+	}{
+		{types: "map[string", err: "column 11: missing ']'"},
+		{types: "int)", err: "column 4: unexpected ')'"},
+		{types: "int, map[]int", err: "column 10: invalid type map[]int: "},
+		{types: "int, 1+2", err: "column 6: 1+2 is not a type"},
+		{types: "int; ", err: "column 5: empty concrete type"},
+		{types: "int, [...]int", err: "column 6: [...]int is not a type"},
+	}
+
+	for _, d := range data {
+		_, err := New(d.types)
+		if assert.Error(t, err, d.types) {
+			assert.True(t, strings.HasPrefix(err.Error(), d.err), "expected '%v', got '%v'", d.err, err)
+		}
+	}
+}
+
+func TestSuffix(t *testing.T) {
+	data := []struct {
+		typ, suffix string
+	}{
+		{typ: "int64", suffix: "Int64"},
+		{typ: "*int32", suffix: "Pint32"},
+		{typ: "*models.User", suffix: "PmodelsUser"},
+		{typ: "map[string]int", suffix: "MapStringInt"},
+		{typ: "func(a, b int) bool", suffix: "FuncIntIntBool"},
+		{typ: "struct{X, Y int}", suffix: "StructXIntYInt"},
+		{typ: "chan<- []byte", suffix: "SendChanSliceByte"},
+		{typ: "[4]*int", suffix: "Array4Pint"},
+		{typ: "interface{ String() string }", suffix: "InterfaceString"},
+	}
+
+	for _, d := range data {
+		assert.Equal(t, d.suffix, Suffix(d.typ), d.typ)
+	}
+}
+
+func TestNamedInstances(t *testing.T) {
+	c, err := New("Users=string,*models.User; int,map[string]int;Counts = string, int")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(c.Instance))
+	assert.Equal(t, Types{"string", "*models.User"}, c.Instance[0])
+	assert.Equal(t, Types{"int", "map[string]int"}, c.Instance[1])
+	assert.Equal(t, Types{"string", "int"}, c.Instance[2])
+	assert.Equal(t, "Users", c.Name(0))
+	assert.Equal(t, "", c.Name(1))
+	assert.Equal(t, "Counts", c.Name(2))
+}
+
+func TestInvalidNamedInstances(t *testing.T) {
+	data := []struct {
+		types, err string
+	}{
+		{types: "func=int", err: "column 1: the instance name func is a keyword"},
+		{types: "int;_=string", err: "column 5: the blank identifier can not be an instance name"},
+		{types: "a b=int", err: "column 3: invalid type a b=int: "},
+		{types: "A=int;A=string", err: "instance 1 and instance 2 are both named A"},
+		{types: "Int64=string;int64", err: "instance 1 and instance 2 are both named Int64"},
+		{types: "int;int", err: "instance 1 and instance 2 are both named Int"},
+		{types: "A=", err: "column 3: empty concrete type"},
+	}
+
+	for _, d := range data {
+		_, err := New(d.types)
+		if assert.Error(t, err, d.types) {
+			assert.True(t, strings.HasPrefix(err.Error(), d.err), "expected '%v', got '%v'", d.err, err)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package concrete
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// Import is a package which is referenced by a fully qualified concrete type
+// like github.com/acme/app/models.User.
+type Import struct {
+	// Name is the name which is used in the concrete types to access the package
+	Name string
+	// Path is the import path of the package
+	Path string
+}
+
+// Spec creates the import spec which is needed to access the package by its name.
+// An alias is used if the name differs from the last element of the import path.
+func (imp Import) Spec() *ast.ImportSpec {
+	spec := &ast.ImportSpec{Path: &ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(imp.Path)}}
+	if imp.Name != path.Base(imp.Path) {
+		spec.Name = ast.NewIdent(imp.Name)
+	}
+	return spec
+}
+
+// a import path which contains at least one slash followed by a dot and an identifier
+var qualifiedType = regexp.MustCompile(`([\w\-~.]+(?:/[\w\-~.]+)+)\.([\pL_][\pL\pN_]*)`)
+
+// shift describes a replacement of a qualified import path by the package name
+type shift struct {
+	// the position in the new text behind the replacement
+	pos int
+	// the number of bytes the text became shorter
+	removed int
+}
+
+// qualify replaces all fully qualified types by types qualified by the package name.
+// The shifts returned are needed to calculate the original positions in the text.
+func (i *Instances) qualify(text string) (string, []shift) {
+	var shifts []shift
+	var buffer bytes.Buffer
+	last := 0
+	quoted := quotedRanges(text)
+	for _, m := range qualifiedType.FindAllStringSubmatchIndex(text, -1) {
+		if isQuoted(m[0], quoted) {
+			// a string literal like a struct tag is not modified
+			continue
+		}
+		name := i.importName(text[m[2]:m[3]])
+		buffer.WriteString(text[last:m[2]])
+		buffer.WriteString(name)
+		shifts = append(shifts, shift{buffer.Len(), m[3] - m[2] - len(name)})
+		last = m[3]
+	}
+	buffer.WriteString(text[last:])
+	return buffer.String(), shifts
+}
+
+// quotedRanges returns the start and end of all string literals in the text.
+// The quotes are found the same way split finds them.
+func quotedRanges(text string) [][2]int {
+	var ranges [][2]int
+	var quote rune
+	start := 0
+	for i, r := range text {
+		if quote != 0 {
+			if r == quote {
+				ranges = append(ranges, [2]int{start, i + 1})
+				quote = 0
+			}
+			continue
+		}
+		if r == '"' || r == '`' {
+			quote = r
+			start = i
+		}
+	}
+	if quote != 0 {
+		ranges = append(ranges, [2]int{start, len(text)})
+	}
+	return ranges
+}
+
+// isQuoted returns true if the position is inside of one of the ranges
+func isQuoted(pos int, ranges [][2]int) bool {
+	for _, r := range ranges {
+		if r[0] <= pos && pos < r[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// origPos calculates the position in the original text from a position in the qualified text
+func origPos(pos int, shifts []shift) int {
+	o := pos
+	for _, s := range shifts {
+		if s.pos <= pos {
+			o += s.removed
+		}
+	}
+	return o
+}
+
+// importName returns the name which is used to access the given package.
+// If there are different packages with the same name, the name is made unique.
+func (i *Instances) importName(importPath string) string {
+	for _, imp := range i.Imports {
+		if imp.Path == importPath {
+			return imp.Name
+		}
+	}
+	base := PackageName(importPath)
+	name := base
+	for n := 2; i.hasImport(name); n++ {
+		name = base + strconv.Itoa(n)
+	}
+	i.Imports = append(i.Imports, Import{Name: name, Path: importPath})
+	return name
+}
+
+func (i *Instances) hasImport(name string) bool {
+	for _, imp := range i.Imports {
+		if imp.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+var versionElement = regexp.MustCompile(`^v[0-9]+$`)
+
+// PackageName guesses the name of a package from its import path.
+// Major version elements like "v2" and suffixes like ".v3" are ignored,
+// and a "go-" prefix is removed.
+func PackageName(importPath string) string {
+	elements := strings.Split(importPath, "/")
+	name := elements[len(elements)-1]
+	if versionElement.MatchString(name) && len(elements) > 1 {
+		name = elements[len(elements)-2]
+	}
+	if p := strings.Index(name, "."); p >= 0 {
+		name = name[:p]
+	}
+	name = strings.TrimPrefix(name, "go-")
+	name = strings.Map(func(r rune) rune {
+		if r == '-' || r == '~' {
+			return -1
+		}
+		return r
+	}, name)
+	if name == "" || !token.IsIdentifier(name) {
+		return "pkg"
+	}
+	return name
+}
+
+// RenameImports renames the package names used in the concrete types.
+// The map contains the new name for every old name. If the new name is empty,
+// the qualifier is removed, which is needed if the generated code lives in the
+// referenced package itself. In this case the package is also removed from the imports.
+func (i *Instances) RenameImports(names map[string]string) {
+	for _, types := range i.Instance {
+		for j, t := range types {
+			types[j] = renameQualifiers(t, names)
+		}
+	}
+	var imports []Import
+	for _, imp := range i.Imports {
+		if newName, ok := names[imp.Name]; ok {
+			if newName == "" {
+				continue
+			}
+			imp.Name = newName
+		}
+		imports = append(imports, imp)
+	}
+	i.Imports = imports
+}
+
+func renameQualifiers(typ string, names map[string]string) string {
+	expr, err := parser.ParseExpr(typ)
+	if err != nil {
+		return typ
+	}
+	modified := false
+	expr = astutil.Apply(expr, func(c *astutil.Cursor) bool {
+		if sel, ok := c.Node().(*ast.SelectorExpr); ok {
+			if id, ok := sel.X.(*ast.Ident); ok {
+				if newName, ok := names[id.Name]; ok && newName != id.Name {
+					modified = true
+					if newName == "" {
+						c.Replace(sel.Sel)
+					} else {
+						id.Name = newName
+					}
+				}
+			}
+		}
+		return true
+	}, nil).(ast.Expr)
+	if !modified {
+		return typ
+	}
+
+	var buffer bytes.Buffer
+	err = printer.Fprint(&buffer, token.NewFileSet(), expr)
+	if err != nil {
+		return typ
+	}
+	return buffer.String()
+}
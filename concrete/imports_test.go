@@ -0,0 +1,77 @@
+package concrete
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQualifiedTypes(t *testing.T) {
+	c, err := New("*github.com/acme/app/models.User;map[string]github.com/acme/app/models.Group;[]gopkg.in/yaml.v3.Node")
+	assert.NoError(t, err)
+	assert.Equal(t, Types{"*models.User"}, c.Instance[0])
+	assert.Equal(t, Types{"map[string]models.Group"}, c.Instance[1])
+	assert.Equal(t, Types{"[]yaml.Node"}, c.Instance[2])
+	assert.Equal(t, []Import{
+		{Name: "models", Path: "github.com/acme/app/models"},
+		{Name: "yaml", Path: "gopkg.in/yaml.v3"},
+	}, c.Imports)
+}
+
+func TestQualifiedTypesNameClash(t *testing.T) {
+	c, err := New("github.com/acme/app/models.User,github.com/acme/lib/models.User")
+	assert.NoError(t, err)
+	assert.Equal(t, Types{"models.User", "models2.User"}, c.Instance[0])
+	assert.Equal(t, []Import{
+		{Name: "models", Path: "github.com/acme/app/models"},
+		{Name: "models2", Path: "github.com/acme/lib/models"},
+	}, c.Imports)
+}
+
+func TestQualifiedTypesWithTags(t *testing.T) {
+	c, err := New("struct{ x a/b.C `tag:\"x/y.Z\"`; s string \"s/t.U\" }")
+	assert.NoError(t, err)
+	assert.Equal(t, Types{"struct{ x b.C `tag:\"x/y.Z\"`; s string \"s/t.U\" }"}, c.Instance[0])
+	assert.Equal(t, []Import{{Name: "b", Path: "a/b"}}, c.Imports)
+}
+
+func TestQualifiedTypeError(t *testing.T) {
+	_, err := New("int, map[github.com/acme/app/models.User]")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "column 42: invalid type")
+	}
+}
+
+func TestPackageName(t *testing.T) {
+	data := []struct {
+		path, name string
+	}{
+		{path: "github.com/acme/app/models", name: "models"},
+		{path: "github.com/acme/app/v2", name: "app"},
+		{path: "gopkg.in/yaml.v3", name: "yaml"},
+		{path: "github.com/acme/go-pool", name: "pool"},
+		{path: "github.com/acme/my-lib", name: "mylib"},
+	}
+
+	for _, d := range data {
+		assert.Equal(t, d.name, PackageName(d.path), d.path)
+	}
+}
+
+func TestRenameImports(t *testing.T) {
+	c, err := New("*github.com/acme/app/models.User,github.com/acme/lib/util.Flag;[]github.com/acme/app/models.Group,int")
+	assert.NoError(t, err)
+	c.RenameImports(map[string]string{"models": "", "util": "util2"})
+	assert.Equal(t, Types{"*User", "util2.Flag"}, c.Instance[0])
+	assert.Equal(t, Types{"[]Group", "int"}, c.Instance[1])
+	assert.Equal(t, []Import{{Name: "util2", Path: "github.com/acme/lib/util"}}, c.Imports)
+}
+
+func TestImportSpec(t *testing.T) {
+	spec := Import{Name: "models", Path: "github.com/acme/app/models"}.Spec()
+	assert.Nil(t, spec.Name)
+	assert.Equal(t, `"github.com/acme/app/models"`, spec.Path.Value)
+
+	spec = Import{Name: "yaml", Path: "gopkg.in/yaml.v3"}.Spec()
+	assert.Equal(t, "yaml", spec.Name.Name)
+}
@@ -0,0 +1,105 @@
+// Package config reads the manifest file which describes several generations,
+// so they can be created in a single run of yagi.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Generation describes a single output which is to create.
+// The fields correspond to the command line flags of yagi.
+type Generation struct {
+	// Template is the template file or the template package directory
+	Template string `json:"template" yaml:"template"`
+	// Out is the output file, if empty it is derived from the template
+	Out string `json:"out" yaml:"out"`
+	// Package is the package name of the output, if empty it is derived from the directory
+	Package string `json:"package" yaml:"package"`
+	// Gen are the concrete types like in the -gen flag
+	Gen string `json:"gen" yaml:"gen"`
+	// Instances are the concrete types of each instance.
+	// They can be used instead of Gen or in addition to it.
+	Instances []string `json:"instances" yaml:"instances"`
+	// Tests enables the generation of the tests
+	Tests bool `json:"tests" yaml:"tests"`
+	// Split creates an output file for each template file
+	Split bool `json:"split" yaml:"split"`
+	// Line enables the //line directives
+	Line bool `json:"line" yaml:"line"`
+	// Imports enables go imports, the default is true
+	Imports *bool `json:"imports" yaml:"imports"`
+	// TypeCheck enables the type check of the generated code, the default is true
+	TypeCheck *bool `json:"typecheck" yaml:"typecheck"`
+}
+
+// Types returns the concrete types of all instances in the format used by the -gen flag
+func (g Generation) Types() string {
+	var instances []string
+	if strings.TrimSpace(g.Gen) != "" {
+		instances = append(instances, g.Gen)
+	}
+	return strings.Join(append(instances, g.Instances...), ";")
+}
+
+// Manifest holds all the generations
+type Manifest struct {
+	Generate []Generation `json:"generate" yaml:"generate"`
+}
+
+// Read reads a manifest. The format is determined by the file
+// extension, which has to be .json, .yaml or .yml.
+func Read(name string) (*Manifest, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var m Manifest
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&m)
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(&m)
+	default:
+		return nil, fmt.Errorf("unknown format of config file %v, use .json, .yaml or .yml", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading config file %v: %v", name, err)
+	}
+
+	err = m.check()
+	if err != nil {
+		return nil, fmt.Errorf("reading config file %v: %v", name, err)
+	}
+	return &m, nil
+}
+
+func (m *Manifest) check() error {
+	if len(m.Generate) == 0 {
+		return errors.New("no generations found")
+	}
+	for i, g := range m.Generate {
+		if g.Template == "" {
+			return fmt.Errorf("generation %d: no template given", i+1)
+		}
+		if g.Types() == "" {
+			return fmt.Errorf("generation %d: no concrete types given", i+1)
+		}
+		if g.Split && g.Out != "" {
+			return fmt.Errorf("generation %d: out can not be used together with split", i+1)
+		}
+	}
+	return nil
+}
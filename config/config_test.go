@@ -0,0 +1,81 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfig(t *testing.T, dir, name, content string) string {
+	file := filepath.Join(dir, name)
+	assert.NoError(t, os.WriteFile(file, []byte(content), 0644))
+	return file
+}
+
+func TestRead(t *testing.T) {
+	dir, err := os.MkdirTemp("", "yagi")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	yamlFile := writeConfig(t, dir, "yagi.yaml", `generate:
+  - template: list/temp/list.go
+    out: list/list.go
+    gen: int64
+    instances:
+      - int32
+      - Users=*models.User
+    tests: true
+  - template: mmap/temp/mmap.go
+    gen: string,int64;string,string
+    imports: false
+`)
+	jsonFile := writeConfig(t, dir, "yagi.json", `{"generate": [
+  {"template": "list/temp/list.go", "out": "list/list.go", "gen": "int64", "instances": ["int32", "Users=*models.User"], "tests": true},
+  {"template": "mmap/temp/mmap.go", "gen": "string,int64;string,string", "imports": false}
+]}`)
+
+	for _, name := range []string{yamlFile, jsonFile} {
+		m, err := Read(name)
+		if assert.NoError(t, err, name) {
+			assert.Equal(t, 2, len(m.Generate))
+			list := m.Generate[0]
+			assert.Equal(t, "list/temp/list.go", list.Template)
+			assert.Equal(t, "list/list.go", list.Out)
+			assert.Equal(t, "int64;int32;Users=*models.User", list.Types())
+			assert.True(t, list.Tests)
+			assert.Nil(t, list.Imports)
+
+			mmap := m.Generate[1]
+			assert.Equal(t, "string,int64;string,string", mmap.Types())
+			if assert.NotNil(t, mmap.Imports) {
+				assert.False(t, *mmap.Imports)
+			}
+		}
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	dir, err := os.MkdirTemp("", "yagi")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	data := []struct {
+		name, content, err string
+	}{
+		{name: "yagi.toml", content: "", err: "unknown format"},
+		{name: "empty.yaml", content: "generate: []\n", err: "no generations found"},
+		{name: "noTemp.yaml", content: "generate:\n  - gen: int\n", err: "generation 1: no template given"},
+		{name: "noGen.json", content: `{"generate": [{"template": "a.go"}]}`, err: "generation 1: no concrete types given"},
+		{name: "split.json", content: `{"generate": [{"template": "a", "gen": "int", "split": true, "out": "a.go"}]}`, err: "out can not be used together with split"},
+		{name: "unknown.yaml", content: "generate:\n  - template: a.go\n    types: int\n", err: "field types not found"},
+		{name: "unknown.json", content: `{"generate": [{"template": "a.go", "types": "int"}]}`, err: "unknown field"},
+	}
+	for _, d := range data {
+		_, err := Read(writeConfig(t, dir, d.name, d.content))
+		if assert.Error(t, err, d.name) {
+			assert.Contains(t, err.Error(), d.err, d.name)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+// Package diff creates unified diffs of text files
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// the number of unchanged lines shown around a change
+const context = 3
+
+type opKind int
+
+const (
+	equal opKind = iota
+	deleted
+	inserted
+)
+
+// op is a single line of the edit script
+type op struct {
+	kind opKind
+	// the index of the line in a and b
+	a, b int
+}
+
+// Unified returns the unified diff of a and b. The names are written to the
+// header of the diff. If a and b are equal, the empty string is returned.
+func Unified(nameA, nameB string, a, b []byte) string {
+	linesA := splitLines(a)
+	linesB := splitLines(b)
+	ops := editScript(linesA, linesB)
+
+	var buf bytes.Buffer
+	for i := 0; i < len(ops); {
+		if ops[i].kind == equal {
+			i++
+			continue
+		}
+		// found a change, collect all changes which are not
+		// separated by more than two times the context
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for j := i; j < len(ops); j++ {
+			if ops[j].kind != equal {
+				end = j + 1
+			} else if j-end >= 2*context {
+				break
+			}
+		}
+		end += context
+		if end > len(ops) {
+			end = len(ops)
+		}
+
+		if buf.Len() == 0 {
+			fmt.Fprintf(&buf, "--- %s\n+++ %s\n", nameA, nameB)
+		}
+		writeHunk(&buf, ops[start:end], linesA, linesB)
+		i = end
+	}
+	return buf.String()
+}
+
+func writeHunk(buf *bytes.Buffer, ops []op, a, b []string) {
+	countA, countB := 0, 0
+	for _, o := range ops {
+		if o.kind != inserted {
+			countA++
+		}
+		if o.kind != deleted {
+			countB++
+		}
+	}
+	fmt.Fprintf(buf, "@@ -%s +%s @@\n", hunkRange(ops[0].a, countA), hunkRange(ops[0].b, countB))
+	for _, o := range ops {
+		switch o.kind {
+		case equal:
+			writeLine(buf, " ", a[o.a])
+		case deleted:
+			writeLine(buf, "-", a[o.a])
+		case inserted:
+			writeLine(buf, "+", b[o.b])
+		}
+	}
+}
+
+// writeLine writes a line of a hunk. A missing newline at the end of the file is marked like diff does.
+func writeLine(buf *bytes.Buffer, prefix, line string) {
+	buf.WriteString(prefix + line)
+	if !strings.HasSuffix(line, "\n") {
+		buf.WriteString("\n\\ No newline at end of file\n")
+	}
+}
+
+// hunkRange creates the range of a hunk. The line numbers start with one,
+// and an empty range refers to the line in front of it.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// splitLines splits the text into lines, which keep their newlines,
+// so a missing newline at the end of the file is a difference.
+func splitLines(text []byte) []string {
+	if len(text) == 0 {
+		return nil
+	}
+	lines := strings.SplitAfter(string(text), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// maxEdits limits the number of edits the Myers algorithm searches for. The memory
+// needed grows with the square of the edits, so a larger change is written as a
+// replacement of all lines between the unchanged lines at the start and the end.
+const maxEdits = 1000
+
+// editScript creates the edit script of a and b. The lines which are equal at the
+// start and at the end are skipped, the lines in between are compared by myers.
+func editScript(a, b []string) []op {
+	start := 0
+	for start < len(a) && start < len(b) && a[start] == b[start] {
+		start++
+	}
+	endA, endB := len(a), len(b)
+	for endA > start && endB > start && a[endA-1] == b[endB-1] {
+		endA--
+		endB--
+	}
+
+	var ops []op
+	for i := 0; i < start; i++ {
+		ops = append(ops, op{kind: equal, a: i, b: i})
+	}
+	middle := myers(a[start:endA], b[start:endB])
+	if middle == nil {
+		for i := start; i < endA; i++ {
+			middle = append(middle, op{kind: deleted, a: i - start, b: 0})
+		}
+		for i := start; i < endB; i++ {
+			middle = append(middle, op{kind: inserted, a: endA - start, b: i - start})
+		}
+	}
+	for _, o := range middle {
+		ops = append(ops, op{kind: o.kind, a: o.a + start, b: o.b + start})
+	}
+	for i := 0; i < len(a)-endA; i++ {
+		ops = append(ops, op{kind: equal, a: endA + i, b: endB + i})
+	}
+	return ops
+}
+
+// myers creates the shortest edit script using the algorithm of Eugene W. Myers.
+// It returns nil if the script needs more than maxEdits edits.
+func myers(a, b []string) []op {
+	n, m := len(a), len(b)
+	max := n + m
+	if max == 0 {
+		return []op{}
+	}
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	// the trace only stores the part of v which is read in the step d
+	var trace [][]int
+	for d := 0; d <= max && d <= maxEdits; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(trace, n, m)
+			}
+		}
+	}
+	return nil
+}
+
+// backtrack follows the trace of the Myers algorithm back to the start
+func backtrack(trace [][]int, x, y int) []op {
+	var ops []op
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		offset := d + 1
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			ops = append(ops, op{kind: equal, a: x, b: y})
+		}
+		if d > 0 {
+			if x == prevX {
+				ops = append(ops, op{kind: inserted, a: x, b: prevY})
+			} else {
+				ops = append(ops, op{kind: deleted, a: prevX, b: y})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	// reverse the ops
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
@@ -0,0 +1,120 @@
+package diff
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func lines(n int) []string {
+	var l []string
+	for i := 1; i <= n; i++ {
+		l = append(l, string(rune('a'+i-1)))
+	}
+	return l
+}
+
+func text(l []string) []byte {
+	return []byte(strings.Join(l, "\n") + "\n")
+}
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		diff string
+	}{
+		{name: "equal", a: "a\nb\n", b: "a\nb\n", diff: ""},
+		{name: "empty", a: "", b: "", diff: ""},
+		{name: "create", a: "", b: "a\nb\n", diff: "--- a\n+++ b\n@@ -0,0 +1,2 @@\n+a\n+b\n"},
+		{name: "delete", a: "a\nb\n", b: "", diff: "--- a\n+++ b\n@@ -1,2 +0,0 @@\n-a\n-b\n"},
+		{name: "change", a: "a\nb\nc\n", b: "a\nx\nc\n", diff: "--- a\n+++ b\n@@ -1,3 +1,3 @@\n a\n-b\n+x\n c\n"},
+		{name: "insert", a: "a\nb\n", b: "a\nx\nb\n", diff: "--- a\n+++ b\n@@ -1,2 +1,3 @@\n a\n+x\n b\n"},
+		{name: "single", a: "a\n", b: "b\n", diff: "--- a\n+++ b\n@@ -1 +1 @@\n-a\n+b\n"},
+		{name: "newline", a: "a\nb", b: "a\nb\n", diff: "--- a\n+++ b\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.diff, Unified("a", "b", []byte(test.a), []byte(test.b)))
+		})
+	}
+}
+
+func TestUnifiedHunks(t *testing.T) {
+	a := lines(20)
+	b := append([]string(nil), a...)
+	b[1] = "B"
+	b[18] = "S"
+	assert.Equal(t, `--- a
++++ b
+@@ -1,5 +1,5 @@
+ a
+-b
++B
+ c
+ d
+ e
+@@ -16,5 +16,5 @@
+ p
+ q
+ r
+-s
++S
+ t
+`, Unified("a", "b", text(a), text(b)))
+
+	// changes separated by six lines are combined to a single hunk
+	b = append([]string(nil), a...)
+	b[1] = "B"
+	b[8] = "I"
+	assert.Equal(t, `--- a
++++ b
+@@ -1,12 +1,12 @@
+ a
+-b
++B
+ c
+ d
+ e
+ f
+ g
+ h
+-i
++I
+ j
+ k
+ l
+`, Unified("a", "b", text(a), text(b)))
+}
+
+func TestUnifiedLarge(t *testing.T) {
+	var a, b []string
+	for i := 0; i < 5000; i++ {
+		a = append(a, fmt.Sprintf("a%d", i))
+		b = append(b, fmt.Sprintf("b%d", i))
+	}
+	a = append([]string{"x"}, append(a, "y")...)
+	b = append([]string{"x"}, append(b, "y")...)
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	d := Unified("a", "b", text(a), text(b))
+	runtime.ReadMemStats(&after)
+	assert.Less(t, after.TotalAlloc-before.TotalAlloc, uint64(100<<20))
+
+	// the whole file is replaced by a single hunk
+	assert.Equal(t, 1, strings.Count(d, "@@ -1,5002 +1,5002 @@\n"))
+	assert.Equal(t, 5000, strings.Count(d, "\n-a"))
+	assert.Equal(t, 5000, strings.Count(d, "\n+b"))
+	assert.True(t, strings.HasPrefix(d, "--- a\n+++ b\n@@ -1,5002 +1,5002 @@\n x\n-a0\n"), d[:40])
+	assert.True(t, strings.HasSuffix(d, "+b4999\n y\n"))
+
+	// a small change in a large file is found exactly
+	b = append([]string(nil), a...)
+	b[2500] = "changed"
+	assert.Equal(t, "--- a\n+++ b\n@@ -2498,7 +2498,7 @@\n a2496\n a2497\n a2498\n-a2499\n+changed\n a2500\n a2501\n a2502\n",
+		Unified("a", "b", text(a), text(b)))
+}
@@ -0,0 +1,253 @@
+// Package discover finds the //yagi:instantiate directives in the go files
+// of a directory tree. A directive requests a generation like a go:generate
+// line, but without the need to run go generate:
+//
+//	//yagi:instantiate ./temp/list.go int64;string
+//
+// The first field is the template, the following fields are the concrete types.
+// Fields starting with a minus are flags like -out=list.go or -tests.
+package discover
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hneemann/yagi/concrete"
+	"github.com/hneemann/yagi/names"
+)
+
+const prefix = "//yagi:instantiate"
+
+// Request is a generation requested by a directive
+type Request struct {
+	// Dir is the directory of the file containing the directive.
+	// All paths of the request are relative to this directory.
+	Dir string
+	// Template is the template file or the template package directory
+	Template string
+	// Gen are the concrete types like in the -gen flag
+	Gen string
+	// Out is the output file, if empty it is derived from the template
+	Out string
+	// Package is the package name of the output
+	Package string
+	// Tests, Split and Line correspond to the command line flags
+	Tests, Split, Line bool
+	// Pos is the position of the directive
+	Pos token.Position
+}
+
+// key returns a string which is equal for identical requests
+func (r Request) key() string {
+	return fmt.Sprintf("%s|%s|%s|%s|%s|%v|%v|%v", r.Dir, filepath.Clean(r.Template), strings.Join(strings.Fields(r.Gen), " "),
+		filepath.Clean(r.Out), r.Package, r.Tests, r.Split, r.Line)
+}
+
+// output returns the name of the output file. If split is set, the output
+// files are not known, so the directory and the template are used.
+func (r Request) output() string {
+	if r.Split {
+		return filepath.Join(r.Dir, filepath.Clean(r.Template)) + " (split)"
+	}
+	return filepath.Join(r.Dir, names.OutName(r.Out, r.Template))
+}
+
+// Find finds all directives in the directories given by the patterns.
+// A pattern is a directory, or a directory followed by "/..." to include
+// all sub directories. Identical requests are only returned once. If
+// different requests create the same output file, an error is returned.
+func Find(patterns []string) ([]Request, error) {
+	var dirs []string
+	for _, p := range patterns {
+		d, err := expand(p)
+		if err != nil {
+			return nil, err
+		}
+		dirs = append(dirs, d...)
+	}
+
+	var errs scanner.ErrorList
+	var requests []Request
+	found := map[string]bool{}
+	outputs := map[string]Request{}
+	for _, dir := range dirs {
+		r, err := findInDir(dir, &errs)
+		if err != nil {
+			return nil, err
+		}
+		for _, req := range r {
+			key := req.key()
+			if found[key] {
+				continue
+			}
+			found[key] = true
+
+			out := req.output()
+			if other, ok := outputs[out]; ok {
+				errs.Add(req.Pos, fmt.Sprintf("%s is also created by the directive at %v", out, other.Pos))
+				continue
+			}
+			outputs[out] = req
+			requests = append(requests, req)
+		}
+	}
+	errs.Sort()
+	return requests, errs.Err()
+}
+
+// expand returns the directories matching the pattern
+func expand(pattern string) ([]string, error) {
+	root := pattern
+	recursive := false
+	if pattern == "..." || strings.HasSuffix(pattern, "/...") {
+		root = strings.TrimSuffix(strings.TrimSuffix(pattern, "..."), "/")
+		if root == "" {
+			root = "."
+		}
+		recursive = true
+	}
+	root = filepath.Clean(root)
+
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", root)
+	}
+	if !recursive {
+		return []string{root}, nil
+	}
+
+	var dirs []string
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		name := info.Name()
+		if path != root && (name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+			return filepath.SkipDir
+		}
+		dirs = append(dirs, path)
+		return nil
+	})
+	return dirs, err
+}
+
+// findInDir returns the requests found in the go files of the directory.
+// Files created by yagi are skipped. Errors in the files are added to errs.
+func findInDir(dir string, errs *scanner.ErrorList) ([]Request, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var fileNames []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".go") {
+			fileNames = append(fileNames, filepath.Join(dir, entry.Name()))
+		}
+	}
+	sort.Strings(fileNames)
+
+	fset := token.NewFileSet()
+	var requests []Request
+	for _, name := range fileNames {
+		generated, err := names.IsGenerated(name)
+		if err != nil {
+			return nil, err
+		}
+		if generated {
+			continue
+		}
+		file, err := parser.ParseFile(fset, name, nil, parser.ParseComments)
+		if err != nil {
+			if list, ok := err.(scanner.ErrorList); ok {
+				*errs = append(*errs, list...)
+				continue
+			}
+			return nil, err
+		}
+		for _, c := range directives(file) {
+			req, err := parseDirective(c.Text)
+			pos := fset.Position(c.Pos())
+			if err != nil {
+				errs.Add(pos, err.Error())
+				continue
+			}
+			req.Dir = dir
+			req.Pos = pos
+			requests = append(requests, req)
+		}
+	}
+	return requests, nil
+}
+
+// directives returns all comments of the file which are directives
+func directives(file *ast.File) []*ast.Comment {
+	var comments []*ast.Comment
+	for _, cg := range file.Comments {
+		for _, c := range cg.List {
+			if c.Text == prefix || strings.HasPrefix(c.Text, prefix+" ") || strings.HasPrefix(c.Text, prefix+"\t") {
+				comments = append(comments, c)
+			}
+		}
+	}
+	return comments
+}
+
+// parseDirective parses the text of a directive
+func parseDirective(text string) (Request, error) {
+	fields := strings.Fields(strings.TrimPrefix(text, prefix))
+	if len(fields) == 0 {
+		return Request{}, fmt.Errorf("no template given")
+	}
+
+	var req Request
+	req.Template = fields[0]
+
+	var flags, types []string
+	for _, f := range fields[1:] {
+		if strings.HasPrefix(f, "-") {
+			flags = append(flags, f)
+		} else {
+			types = append(types, f)
+		}
+	}
+	req.Gen = strings.Join(types, " ")
+	if req.Gen == "" {
+		return Request{}, fmt.Errorf("no concrete types given")
+	}
+	_, err := concrete.New(req.Gen)
+	if err != nil {
+		return Request{}, fmt.Errorf("processing concrete types: %v", err)
+	}
+
+	fs := flag.NewFlagSet(prefix, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.StringVar(&req.Out, "out", "", "")
+	fs.StringVar(&req.Package, "pac", "", "")
+	fs.BoolVar(&req.Tests, "tests", false, "")
+	fs.BoolVar(&req.Split, "split", false, "")
+	fs.BoolVar(&req.Line, "line", false, "")
+	err = fs.Parse(flags)
+	if err != nil {
+		return Request{}, err
+	}
+	if req.Split && req.Out != "" {
+		return Request{}, fmt.Errorf("the -out flag can not be used together with -split")
+	}
+	return req, nil
+}
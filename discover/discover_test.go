@@ -0,0 +1,105 @@
+package discover
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hneemann/yagi/names"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDirective(t *testing.T) {
+	data := []struct {
+		text string
+		req  Request
+		err  string
+	}{
+		{text: "//yagi:instantiate ./temp/list.go int64;string", req: Request{Template: "./temp/list.go", Gen: "int64;string"}},
+		{text: "//yagi:instantiate ./temp/list.go -tests Fn=func(a int) bool -out=fn.go", req: Request{Template: "./temp/list.go", Gen: "Fn=func(a int) bool", Out: "fn.go", Tests: true}},
+		{text: "//yagi:instantiate temp -split -pac=list -line int", req: Request{Template: "temp", Gen: "int", Package: "list", Split: true, Line: true}},
+		{text: "//yagi:instantiate", err: "no template given"},
+		{text: "//yagi:instantiate list.go", err: "no concrete types given"},
+		{text: "//yagi:instantiate list.go map[int", err: "processing concrete types"},
+		{text: "//yagi:instantiate list.go int -unknown", err: "flag provided but not defined: -unknown"},
+		{text: "//yagi:instantiate list.go int -split -out=a.go", err: "can not be used together"},
+	}
+	for _, d := range data {
+		req, err := parseDirective(d.text)
+		if d.err == "" {
+			assert.NoError(t, err, d.text)
+			assert.Equal(t, d.req, req, d.text)
+		} else if assert.Error(t, err, d.text) {
+			assert.Contains(t, err.Error(), d.err, d.text)
+		}
+	}
+}
+
+func writeFile(t *testing.T, name, content string) {
+	assert.NoError(t, os.MkdirAll(filepath.Dir(name), 0755))
+	assert.NoError(t, os.WriteFile(name, []byte(content), 0644))
+}
+
+func TestFind(t *testing.T) {
+	dir, err := os.MkdirTemp("", "yagi")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	writeFile(t, filepath.Join(dir, "a", "a.go"), `package a
+
+//yagi:instantiate ./temp/list.go int64;string
+func A() {}
+`)
+	writeFile(t, filepath.Join(dir, "a", "b.go"), `package a
+
+//yagi:instantiate ./temp/list.go int64;string
+//yagi:instantiate ./temp/map.go string,int -out=map.go
+`)
+	writeFile(t, filepath.Join(dir, "a", "c", "c.go"), `package c
+
+//yagi:instantiate ../temp/list.go int
+`)
+	writeFile(t, filepath.Join(dir, "a", "testdata", "t.go"), `package t
+
+//yagi:instantiate ../temp/list.go int
+`)
+	writeFile(t, filepath.Join(dir, "a", "gen.go"), names.Header("temp/list.go", "int", "v1")+`package a
+
+//yagi:instantiate ./temp/list.go int
+`)
+
+	requests, err := Find([]string{filepath.Join(dir, "a") + "/..."})
+	assert.NoError(t, err)
+	if assert.Equal(t, 3, len(requests)) {
+		assert.Equal(t, filepath.Join(dir, "a"), requests[0].Dir)
+		assert.Equal(t, "int64;string", requests[0].Gen)
+		assert.Equal(t, 3, requests[0].Pos.Line)
+		assert.Equal(t, "map.go", requests[1].Out)
+		assert.Equal(t, filepath.Join(dir, "a", "c"), requests[2].Dir)
+	}
+
+	requests, err = Find([]string{filepath.Join(dir, "a")})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(requests))
+}
+
+func TestFindErrors(t *testing.T) {
+	dir, err := os.MkdirTemp("", "yagi")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	writeFile(t, filepath.Join(dir, "a.go"), `package a
+
+//yagi:instantiate ./temp/list.go int64
+//yagi:instantiate ./temp/list.go int32
+//yagi:instantiate ./temp/list.go
+`)
+	_, err = Find([]string{dir})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "a.go:4:1: "+filepath.Join(dir, "list.go")+" is also created by the directive at")
+		assert.Contains(t, err.Error(), "(and 1 more errors)")
+	}
+
+	_, err = Find([]string{filepath.Join(dir, "missing")})
+	assert.Error(t, err)
+}
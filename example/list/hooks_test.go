@@ -0,0 +1,11 @@
+package list
+
+// the test values used by the generated tests
+
+func valueInt64(i int) int64 {
+	return int64(i)
+}
+
+func valueInt32(i int) int32 {
+	return int32(i)
+}
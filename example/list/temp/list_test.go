@@ -0,0 +1,34 @@
+package temp
+
+import (
+	"fmt"
+	"testing"
+)
+
+// The value function is a hook. It is not copied to the generated
+// tests, instead every instance needs its own implementation.
+
+//hook
+func value(i int) ITEM {
+	return ITEM(i)
+}
+
+func TestAdd(t *testing.T) {
+	l := List{}
+	l.Add(value(1))
+	l.Add(value(2))
+	if l.Len() != 2 {
+		t.Errorf("expected two items, got %d", l.Len())
+	}
+	if l.Items()[1] != value(2) {
+		t.Errorf("expected %v, got %v", value(2), l.Items()[1])
+	}
+}
+
+func ExampleList_Len() {
+	l := List{}
+	l.Add(value(1))
+	fmt.Println(l.Len())
+	// Output:
+	// 1
+}
@@ -0,0 +1,557 @@
+// Package generator creates the concrete implementations of a template. It holds the
+// complete pipeline of yagi: it parses the template, creates the concrete declarations,
+// runs go imports and type checks the result. So yagi can be used by other tools
+// without running the command line tool. Nothing is written to disk, the generated
+// sources are returned to the caller.
+package generator
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"strings"
+
+	"github.com/hneemann/yagi/concrete"
+	"github.com/hneemann/yagi/generify"
+	"github.com/hneemann/yagi/infer"
+	"github.com/hneemann/yagi/names"
+	"github.com/hneemann/yagi/typecheck"
+	"golang.org/x/tools/imports"
+)
+
+const modulePath = "github.com/hneemann/yagi"
+
+// Template is the template to use. It is either read from the
+// file system, or its source is given directly.
+type Template struct {
+	// Path is the template file or the template package directory. If Source
+	// is given, Path is only used as the name of the template file.
+	Path string
+	// Source is the source of a template file
+	Source []byte
+	// TestSource is the source of the test file of the template. It is
+	// only used if Source is given and the tests are to be created.
+	TestSource []byte
+}
+
+// Instance holds the concrete types of a single instance
+type Instance struct {
+	// Name is the optional name of the instance, which is used as suffix
+	// instead of the suffixes of the concrete types
+	Name string
+	// Types are the concrete types, one for each generic type
+	Types []string
+}
+
+// Options describes a generation
+type Options struct {
+	// Template is the template to use
+	Template Template
+	// Instances are the instances to create
+	Instances []Instance
+	// Gen are further instances in the format of the -gen flag
+	Gen string
+	// Auto infers the instances from the identifiers which are used in the
+	// package of the output file but not declared
+	Auto bool
+	// Dir is the directory all paths are relative to. If empty, the current directory is used.
+	Dir string
+	// Out is the output file, if empty it is derived from the template.
+	// It is not written, but it determines the package used to resolve the types.
+	Out string
+	// Package is the package name of the output, if empty it is derived from the directory
+	Package string
+	// Tests enables the generation of the tests
+	Tests bool
+	// Split creates an output file for each template file
+	Split bool
+	// LineDirectives enables the //line directives
+	LineDirectives bool
+	// NoImports disables go imports
+	NoImports bool
+	// NoTypeCheck disables the type check of the generated code
+	NoTypeCheck bool
+	// NoOverwriteCheck disables the check that existing output files were created by yagi.
+	// It can be set if the generated files are not written to disk, e.g. if they are printed.
+	NoOverwriteCheck bool
+	// Templates holds the templates parsed by former generations. If nil, the template is parsed.
+	Templates *Templates
+	// Explain receives the result of the dependency analysis if not nil
+	Explain io.Writer
+}
+
+// File is a generated file
+type File struct {
+	// Name is the name of the file
+	Name string
+	// Source is the formatted source
+	Source []byte
+}
+
+// Result is the result of a generation
+type Result struct {
+	// Files are the generated files
+	Files []File
+	// Gen are the concrete types of all instances in the format of the -gen flag
+	Gen string
+	// Diagnostics are the errors found by the type check of the generated code,
+	// mapped back to the template. The files are returned anyway.
+	Diagnostics scanner.ErrorList
+}
+
+// Generate creates the concrete implementations of a template. An error is returned
+// if the output can not be created. Errors in the template are returned as a
+// scanner.ErrorList, so their positions are available.
+func Generate(ctx context.Context, opt Options) (Result, error) {
+	t := opt.Templates
+	if t == nil {
+		t = NewTemplates()
+	}
+	if opt.Split && opt.Out != "" {
+		return Result{}, fmt.Errorf("an output file can not be given if the output is split")
+	}
+
+	// read the source files
+	tem := opt.Template.Path
+	var files []*ast.File
+	var err error
+	if opt.Template.Source != nil {
+		if tem == "" {
+			tem = "template.go"
+		}
+		files, err = t.parse(tem, opt.Template.Source, opt.Template.TestSource, opt.Tests)
+	} else {
+		files, err = t.get(filepath.Join(opt.Dir, tem), opt.Tests)
+	}
+	if err != nil {
+		return Result{}, err
+	}
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+
+	gen := opt.gen()
+	if opt.Auto {
+		if gen != "" {
+			return Result{}, fmt.Errorf("the concrete types can not be given if they are inferred")
+		}
+		if opt.Split {
+			return Result{}, fmt.Errorf("the concrete types can not be inferred if the output is split")
+		}
+		gen, err = inferTypes(t.fset, files, opt.Dir, tem, opt.Out)
+		if err != nil {
+			return Result{}, err
+		}
+	}
+
+	// prepeare the concrete types
+	c, err := concrete.New(gen)
+	if err != nil {
+		return Result{}, fmt.Errorf("processing concrete types: %w", err)
+	}
+
+	g := generation{ctx: ctx, opt: opt, fset: t.fset, files: files, c: c, gen: gen, buffers: map[string]*bytes.Buffer{}}
+	if opt.Split {
+		err = g.generatePerFile()
+	} else {
+		err = g.generate(tem)
+	}
+	if err != nil {
+		return Result{}, err
+	}
+	if opt.Explain != nil {
+		err = g.gener.Explain(opt.Explain)
+		if err != nil {
+			return Result{}, err
+		}
+	}
+	return g.result()
+}
+
+// gen returns the instances in the format of the -gen flag
+func (o Options) gen() string {
+	var instances []string
+	if strings.TrimSpace(o.Gen) != "" {
+		instances = append(instances, o.Gen)
+	}
+	for _, i := range o.Instances {
+		types := strings.Join(i.Types, ",")
+		if i.Name != "" {
+			types = i.Name + "=" + types
+		}
+		instances = append(instances, types)
+	}
+	return strings.Join(instances, ";")
+}
+
+// version returns the version of yagi, which is written to the header of the generated files
+func version() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "devel"
+	}
+	v := ""
+	if info.Main.Path == modulePath {
+		v = info.Main.Version
+	} else {
+		for _, dep := range info.Deps {
+			if dep.Path == modulePath {
+				v = dep.Version
+			}
+		}
+	}
+	if v == "" || v == "(devel)" {
+		return "devel"
+	}
+	return v
+}
+
+// inferTypes infers the concrete types from the identifiers which are used in the package
+// of the output file but not declared. The output file itself is ignored, so the
+// identifiers declared by a former generation are found again.
+func inferTypes(fset *token.FileSet, files []*ast.File, dir, tem, out string) (string, error) {
+	outName := filepath.Join(dir, names.OutName(out, tem))
+	undefined, typeNames, err := typecheck.Undefined(filepath.Dir(outName), outName, strings.TrimSuffix(outName, ".go")+"_test.go")
+	if err != nil {
+		return "", fmt.Errorf("searching undefined identifiers: %w", err)
+	}
+
+	gener := generify.NewPackage(fset, files, nil)
+	genTypes, err := gener.GenericTypes()
+	if err != nil {
+		return "", err
+	}
+	renamed, err := gener.RenamedNames()
+	if err != nil {
+		return "", err
+	}
+	gen, err := infer.Instances(infer.Template{GenericTypes: genTypes, Renamed: renamed}, undefined, typeNames)
+	if err != nil {
+		return "", fmt.Errorf("inferring the concrete types: %w", err)
+	}
+	return gen, nil
+}
+
+// generation holds the state of a single generation
+type generation struct {
+	ctx   context.Context
+	opt   Options
+	fset  *token.FileSet
+	files []*ast.File
+	c     *concrete.Instances
+	// the concrete types which are written to the header
+	gen string
+
+	gener *generify.Generify
+	// the directory of the output files
+	dir      string
+	outNames []string
+	buffers  map[string]*bytes.Buffer
+}
+
+// generate creates a single output file
+func (g *generation) generate(tem string) error {
+	// create output name
+	outName, err := g.outName(filepath.Join(g.opt.Dir, names.OutName(g.opt.Out, tem)), tem)
+	if err != nil {
+		return err
+	}
+
+	packageName, err := names.GetPackageName(g.opt.Package, outName)
+	if err != nil {
+		return err
+	}
+
+	// generify the source files
+	g.dir = filepath.Dir(outName)
+	g.gener = generify.NewPackage(g.fset, g.files, g.c)
+	g.gener.PackagePath = names.GetPackagePath(outName)
+	g.gener.Resolver = typecheck.NewResolver(g.dir, outName, packageName)
+	g.gener.LineDirectives = g.opt.LineDirectives
+	g.gener.OutputDir = g.dir
+	err = g.gener.Do(packageName, g.buffer(outName, tem))
+	if err != nil {
+		return err
+	}
+
+	if g.gener.HasTests() {
+		// create the tests
+		testName, err := g.outName(strings.TrimSuffix(outName, ".go")+"_test.go", tem)
+		if err != nil {
+			return err
+		}
+		err = g.gener.DoTests(packageName, g.buffer(testName, tem))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// generatePerFile creates an output file for each template file
+func (g *generation) generatePerFile() error {
+	// all output files are created in the directory dir
+	g.dir = filepath.Clean(g.opt.Dir)
+	packageName, err := names.GetPackageName(g.opt.Package, filepath.Join(g.dir, "gen.go"))
+	if err != nil {
+		return err
+	}
+
+	g.gener = generify.NewPackage(g.fset, g.files, g.c)
+	g.gener.PackagePath = names.GetPackagePath(filepath.Join(g.dir, "gen.go"))
+	g.gener.Resolver = typecheck.NewResolver(g.dir, "", packageName)
+	g.gener.LineDirectives = g.opt.LineDirectives
+	g.gener.OutputDir = g.dir
+	return g.gener.DoPerFile(packageName, func(templateFile string) (io.Writer, error) {
+		// the name of the template file relative to the output directory
+		rel, err := filepath.Rel(g.dir, templateFile)
+		if err != nil {
+			rel = templateFile
+		}
+		outName, err := g.outName(filepath.Join(g.dir, names.OutName("", rel)), rel)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := g.buffers[outName]; ok {
+			return nil, fmt.Errorf("output file %v is used twice", outName)
+		}
+		return g.buffer(outName, rel), nil
+	})
+}
+
+// outName creates the name of an output file. If the output file already
+// exists, it is checked that this file was created by yagi, if not disabled.
+func (g *generation) outName(out, tem string) (string, error) {
+	if g.opt.NoOverwriteCheck {
+		return names.OutName(out, tem), nil
+	}
+	return names.CreateOutName(out, tem)
+}
+
+// buffer creates the buffer of an output file and writes the header to it
+func (g *generation) buffer(outName, tem string) *bytes.Buffer {
+	buffer := new(bytes.Buffer)
+	buffer.WriteString(names.Header(tem, g.gen, version()))
+	g.buffers[outName] = buffer
+	g.outNames = append(g.outNames, outName)
+	return buffer
+}
+
+// result runs go imports and the type check if requested and creates the result
+func (g *generation) result() (Result, error) {
+	sources := map[string][]byte{}
+	for _, outName := range g.outNames {
+		source := g.buffers[outName].Bytes()
+		if !g.opt.NoImports {
+			// run go imports
+			var err error
+			source, err = imports.Process(outName, source, nil)
+			if err != nil {
+				return Result{}, fmt.Errorf("go imports has an error, try -imp=false: %v", err)
+			}
+		}
+		sources[outName] = source
+	}
+	if err := g.ctx.Err(); err != nil {
+		return Result{}, err
+	}
+
+	r := Result{Gen: g.gen}
+	for _, outName := range g.outNames {
+		r.Files = append(r.Files, File{Name: outName, Source: sources[outName]})
+	}
+	if !g.opt.NoTypeCheck {
+		var err error
+		r.Diagnostics, err = g.typeCheck(sources)
+		if err != nil {
+			return Result{}, err
+		}
+	}
+	return r, nil
+}
+
+// typeCheck type checks the generated code. The errors found are
+// mapped back to the template and the instance which caused them.
+func (g *generation) typeCheck(sources map[string][]byte) (scanner.ErrorList, error) {
+	typeErrors, err := typecheck.Check(g.dir, sources)
+	if err != nil {
+		return nil, fmt.Errorf("type checking the generated code: %w", err)
+	}
+
+	var list scanner.ErrorList
+	for _, te := range typeErrors {
+		pos := te.Fset.PositionFor(te.Pos, false)
+		templatePos, instance, ok := g.gener.Origin(g.buffers[pos.Filename], sources[pos.Filename], pos)
+		switch {
+		case !ok:
+			list.Add(pos, te.Msg)
+		case instance < 0:
+			list.Add(templatePos, te.Msg)
+		default:
+			list.Add(templatePos, fmt.Sprintf("instance %d (%s): %s", instance+1, instanceName(g.c, instance), te.Msg))
+		}
+	}
+	return list, nil
+}
+
+// instanceName returns the name of an instance or its types if it has no name
+func instanceName(c *concrete.Instances, index int) string {
+	if name := c.Name(index); name != "" {
+		return name
+	}
+	return strings.Join(c.Instance[index], ",")
+}
+
+// Templates holds the parsed templates, so a template used by several
+// generations is parsed only once. It must not be used concurrently.
+type Templates struct {
+	fset  *token.FileSet
+	files map[string][]*ast.File
+	tests map[string][]*ast.File
+}
+
+// NewTemplates creates a new, empty Templates instance
+func NewTemplates() *Templates {
+	return &Templates{fset: token.NewFileSet(), files: map[string][]*ast.File{}, tests: map[string][]*ast.File{}}
+}
+
+// get returns the files of the template. If tests is set, the test files are included.
+func (t *Templates) get(tem string, tests bool) ([]*ast.File, error) {
+	files, ok := t.files[tem]
+	if !ok {
+		var err error
+		files, err = parseTemplate(t.fset, tem)
+		if err != nil {
+			return nil, fmt.Errorf("reading source file: %w", err)
+		}
+		t.files[tem] = files
+	}
+	if !tests {
+		return files, nil
+	}
+
+	testFiles, ok := t.tests[tem]
+	if !ok {
+		var err error
+		testFiles, err = parseTests(t.fset, tem, files[0].Name.Name)
+		if err != nil {
+			return nil, fmt.Errorf("reading test file: %w", err)
+		}
+		t.tests[tem] = testFiles
+	}
+	return append(append([]*ast.File(nil), files...), testFiles...), nil
+}
+
+// parse parses a template given by its source. The source is not cached.
+func (t *Templates) parse(tem string, source, testSource []byte, tests bool) ([]*ast.File, error) {
+	file, err := parser.ParseFile(t.fset, tem, source, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("reading source file: %w", err)
+	}
+	files := []*ast.File{file}
+	if !tests {
+		return files, nil
+	}
+	if testSource == nil {
+		return nil, fmt.Errorf("no test files found for %v", tem)
+	}
+	testFile, err := parser.ParseFile(t.fset, strings.TrimSuffix(tem, ".go")+"_test.go", testSource, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("reading test file: %w", err)
+	}
+	return append(files, testFile), nil
+}
+
+// parseTemplate parses the template. If tem is a directory, all go files in this
+// directory are parsed, except test files and files created by yagi.
+func parseTemplate(fset *token.FileSet, tem string) ([]*ast.File, error) {
+	info, err := os.Stat(tem)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		file, err := parser.ParseFile(fset, tem, nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		return []*ast.File{file}, nil
+	}
+
+	fileNames, err := filepath.Glob(filepath.Join(tem, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(fileNames)
+
+	var files []*ast.File
+	for _, name := range fileNames {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		generated, err := names.IsGenerated(name)
+		if err != nil {
+			return nil, err
+		}
+		if generated {
+			continue
+		}
+
+		file, err := parser.ParseFile(fset, name, nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		if len(files) > 0 && files[0].Name.Name != file.Name.Name {
+			return nil, fmt.Errorf("found packages %v and %v in %v", files[0].Name.Name, file.Name.Name, tem)
+		}
+		files = append(files, file)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no go files found in %v", tem)
+	}
+	return files, nil
+}
+
+// parseTests parses the test files of the template. If tem is a file, this is the test
+// file belonging to it, if tem is a directory, these are all test files in the directory.
+// Test files of an external test package are ignored.
+func parseTests(fset *token.FileSet, tem, packageName string) ([]*ast.File, error) {
+	info, err := os.Stat(tem)
+	if err != nil {
+		return nil, err
+	}
+
+	var fileNames []string
+	if info.IsDir() {
+		fileNames, err = filepath.Glob(filepath.Join(tem, "*_test.go"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(fileNames)
+	} else {
+		fileNames = []string{strings.TrimSuffix(tem, ".go") + "_test.go"}
+	}
+
+	var files []*ast.File
+	for _, name := range fileNames {
+		file, err := parser.ParseFile(fset, name, nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		if file.Name.Name == packageName {
+			files = append(files, file)
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no test files found for %v", tem)
+	}
+	return files, nil
+}
@@ -0,0 +1,155 @@
+package generator
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const maxTemplate = `package temp
+
+//generic ordered
+type ITEM int
+
+// Max returns the larger value
+func Max(a, b ITEM) ITEM {
+	if a > b {
+		return a
+	}
+	return b
+}
+`
+
+const maxTest = `package temp
+
+import "testing"
+
+func TestMax(t *testing.T) {
+	if Max(1, 2) != 2 {
+		t.Fail()
+	}
+}
+`
+
+func tempDir(t *testing.T) string {
+	dir, err := os.MkdirTemp("", "yagi")
+	assert.NoError(t, err)
+	return dir
+}
+
+func TestGenerate(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	result, err := Generate(context.Background(), Options{
+		Template:  Template{Path: "temp/max.go", Source: []byte(maxTemplate), TestSource: []byte(maxTest)},
+		Instances: []Instance{{Types: []string{"int64"}}, {Name: "Float", Types: []string{"float64"}}},
+		Dir:       dir,
+		Out:       "max.go",
+		Tests:     true,
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "int64;Float=float64", result.Gen)
+		assert.Empty(t, result.Diagnostics)
+		if assert.Equal(t, 2, len(result.Files)) {
+			assert.Equal(t, filepath.Join(dir, "max.go"), result.Files[0].Name)
+			assert.Equal(t, filepath.Join(dir, "max_test.go"), result.Files[1].Name)
+
+			source := string(result.Files[0].Source)
+			assert.Contains(t, source, "// Code generated by yagi devel from temp/max.go with -gen=int64;Float=float64. DO NOT EDIT.")
+			assert.Contains(t, source, "package "+filepath.Base(dir))
+			assert.Contains(t, source, "func MaxInt64(a, b int64) int64 {")
+			assert.Contains(t, source, "func MaxFloat(a, b float64) float64 {")
+			assert.Contains(t, string(result.Files[1].Source), "func TestMaxInt64(t *testing.T) {")
+		}
+	}
+}
+
+func TestGenerateFromFile(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "temp"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "temp", "max.go"), []byte(maxTemplate), 0644))
+
+	templates := NewTemplates()
+	for _, gen := range []string{"int", "string"} {
+		result, err := Generate(context.Background(), Options{
+			Template:  Template{Path: "temp/max.go"},
+			Gen:       gen,
+			Dir:       dir,
+			Package:   "max",
+			Templates: templates,
+		})
+		if assert.NoError(t, err) && assert.Equal(t, 1, len(result.Files)) {
+			assert.Equal(t, filepath.Join(dir, "max.go"), result.Files[0].Name)
+			assert.Contains(t, string(result.Files[0].Source), "package max")
+		}
+	}
+	// nothing is written
+	_, err := os.Stat(filepath.Join(dir, "max.go"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestGenerateDiagnostics(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	_, err := Generate(context.Background(), Options{
+		Template:  Template{Path: "temp/max.go", Source: []byte(maxTemplate)},
+		Instances: []Instance{{Types: []string{"int"}}, {Types: []string{"[]int"}}},
+		Dir:       dir,
+	})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "which does not satisfy the constraint ordered")
+
+	// without the constraint, the error is found by the type check
+	result, err := Generate(context.Background(), Options{
+		Template:  Template{Path: "temp/max.go", Source: []byte(strings.Replace(maxTemplate, "//generic ordered", "//generic", 1))},
+		Instances: []Instance{{Types: []string{"int"}}, {Types: []string{"[]int"}}},
+		Dir:       dir,
+	})
+	if assert.NoError(t, err) && assert.Equal(t, 1, len(result.Diagnostics)) {
+		d := result.Diagnostics[0]
+		assert.Equal(t, "temp/max.go", d.Pos.Filename)
+		assert.Equal(t, 8, d.Pos.Line)
+		assert.Contains(t, d.Msg, "instance 2 ([]int): invalid operation")
+		assert.Equal(t, 1, len(result.Files))
+	}
+}
+
+func TestGenerateCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := Generate(ctx, Options{
+		Template: Template{Path: "temp/max.go", Source: []byte(maxTemplate)},
+		Gen:      "int",
+	})
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestGenerateOverwriteCheck(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "max.go"), []byte("package max\n"), 0644))
+
+	opt := Options{
+		Template: Template{Path: "temp/max.go", Source: []byte(maxTemplate)},
+		Gen:      "int",
+		Dir:      dir,
+		Package:  "max",
+	}
+	_, err := Generate(context.Background(), opt)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "can not overwrite file")
+	}
+
+	opt.NoOverwriteCheck = true
+	result, err := Generate(context.Background(), opt)
+	if assert.NoError(t, err) && assert.Equal(t, 1, len(result.Files)) {
+		assert.Contains(t, string(result.Files[0].Source), "func MaxInt(a, b int) int {")
+	}
+}
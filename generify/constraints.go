@@ -0,0 +1,165 @@
+package generify
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"github.com/hneemann/yagi/concrete"
+)
+
+// TypeResolver resolves concrete types to go/types types.
+// It is needed to check the constraints of the generic types.
+type TypeResolver interface {
+	// Resolve returns the type of each of the given type expressions.
+	// The imports are the packages which are referenced by the types.
+	Resolve(typeExprs []string, imports []concrete.Import) ([]types.Type, error)
+}
+
+// universeResolver resolves types which only consist of predeclared identifiers
+type universeResolver struct{}
+
+func (universeResolver) Resolve(typeExprs []string, _ []concrete.Import) ([]types.Type, error) {
+	var result []types.Type
+	for _, t := range typeExprs {
+		tv, err := types.Eval(token.NewFileSet(), nil, token.NoPos, t)
+		if err != nil {
+			return nil, fmt.Errorf("can not resolve type %s: %v", t, err)
+		}
+		if !tv.IsType() {
+			return nil, fmt.Errorf("%s is not a type", t)
+		}
+		result = append(result, tv.Type)
+	}
+	return result, nil
+}
+
+// constraint is a requirement which a concrete type has to fulfill.
+// It is given in the comment which marks a generic type, e.g.
+// "//generic ordered" or "//generic implements fmt.Stringer".
+type constraint struct {
+	// the name of the constraint
+	kind string
+	// the interface which the type has to implement, if kind is "implements"
+	iface string
+	// the index of the generic type
+	index int
+	// the position of the comment in the template
+	pos token.Pos
+}
+
+// parseConstraints parses the text of the comment which marks a generic type.
+// The first return value is false if the comment does not mark a generic type.
+// A comment only marks a generic type if all words following "generic" are
+// constraints, so a comment like "generic type of the items" is an ordinary comment.
+func parseConstraints(text string, index int, pos token.Pos) (bool, []constraint) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 || fields[0] != "generic" {
+		return false, nil
+	}
+	var constraints []constraint
+	for i := 1; i < len(fields); i++ {
+		switch fields[i] {
+		case "comparable", "ordered", "numeric":
+			constraints = append(constraints, constraint{kind: fields[i], index: index, pos: pos})
+		case "implements":
+			if i+1 >= len(fields) {
+				return false, nil
+			}
+			i++
+			constraints = append(constraints, constraint{kind: "implements", iface: fields[i], index: index, pos: pos})
+		default:
+			return false, nil
+		}
+	}
+	return true, constraints
+}
+
+// check checks if the given type fulfills the constraint
+func (c constraint) check(t, iface types.Type) bool {
+	switch c.kind {
+	case "comparable":
+		return types.Comparable(t)
+	case "ordered":
+		b, ok := t.Underlying().(*types.Basic)
+		return ok && b.Info()&types.IsOrdered != 0
+	case "numeric":
+		b, ok := t.Underlying().(*types.Basic)
+		return ok && b.Info()&types.IsNumeric != 0
+	case "implements":
+		i, ok := iface.Underlying().(*types.Interface)
+		return ok && types.Implements(t, i)
+	}
+	return false
+}
+
+func (c constraint) String() string {
+	if c.kind == "implements" {
+		return "implements " + c.iface
+	}
+	return c.kind
+}
+
+// checkConstraints checks that all concrete types fulfill the constraints
+// of their generic types.
+func (g *Generify) checkConstraints() error {
+	if len(g.constraints) == 0 {
+		return nil
+	}
+
+	// all types and interfaces are resolved at once
+	var exprs []string
+	index := map[string]int{}
+	add := func(expr string) {
+		if _, ok := index[expr]; !ok {
+			index[expr] = len(exprs)
+			exprs = append(exprs, expr)
+		}
+	}
+	for _, c := range g.constraints {
+		if c.kind == "implements" {
+			add(c.iface)
+		}
+		for _, ct := range g.concreteTypes.Instance {
+			add(ct[c.index])
+		}
+	}
+
+	resolver := g.Resolver
+	if resolver == nil {
+		resolver = universeResolver{}
+	}
+	resolved, err := resolver.Resolve(exprs, g.concreteImports)
+	if err != nil {
+		return g.errorf(g.constraints[0].pos, "checking the constraints: %v", err)
+	}
+
+	for _, c := range g.constraints {
+		var iface types.Type
+		if c.kind == "implements" {
+			iface = resolved[index[c.iface]]
+			if !types.IsInterface(iface) {
+				return g.errorf(c.pos, "%s is not an interface", c.iface)
+			}
+		}
+		for i, ct := range g.concreteTypes.Instance {
+			typ := ct[c.index]
+			if !c.check(resolved[index[typ]], iface) {
+				return g.errorf(c.pos, "instance %d uses %s for the generic type %s, which does not satisfy the constraint %s",
+					i+1, typ, g.genTypes[c.index], c)
+			}
+		}
+	}
+	return nil
+}
+
+// constraintsOf returns the constraints of a generic type declaration.
+// The first return value is false if the declaration is not a generic type.
+func (g *Generify) constraintsOf(gd *ast.GenDecl, index int) (bool, []constraint) {
+	if gd.Doc == nil {
+		return false, nil
+	}
+	return parseConstraints(gd.Doc.Text(), index, gd.Doc.Pos())
+}
@@ -0,0 +1,181 @@
+package generify
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/hneemann/yagi/concrete"
+)
+
+// Explain writes the result of the dependency analysis: For every declaration of the
+// template it writes the generic types it depends on, why it depends on them, the
+// names it gets for the instances and whether it is written once or per instance.
+func (g *Generify) Explain(w io.Writer) error {
+	err := g.prepare()
+	if err != nil {
+		return err
+	}
+
+	for i, name := range g.genTypes {
+		fmt.Fprintf(w, "generic type %s", name)
+		for _, c := range g.constraints {
+			if c.index == i {
+				fmt.Fprintf(w, ", constraint %s", c)
+			}
+		}
+		fmt.Fprintln(w)
+	}
+	for i, types := range g.concreteTypes.Instance {
+		fmt.Fprintf(w, "instance %d: %s\n", i+1, g.instanceTypes(types))
+	}
+
+	for _, decl := range g.genericDecls {
+		if decl.isImport() {
+			continue
+		}
+		fmt.Fprintf(w, "\n%v: %s\n", g.position(decl.decl.Pos()), declName(decl.decl))
+		if !decl.isGeneric() {
+			if decl.hook {
+				fmt.Fprintln(w, "\tdepends on no generic type, it is a hook which is never written")
+			} else {
+				fmt.Fprintln(w, "\tdepends on no generic type, written once")
+			}
+			continue
+		}
+
+		indices := decl.usedTypes.Items()
+		sort.Ints(indices)
+		for _, i := range indices {
+			fmt.Fprintf(w, "\tdepends on %s: %s\n", g.genTypes[i], g.explainReason(decl, i))
+		}
+		if decl.hook {
+			fmt.Fprintln(w, "\trenamed like all other declarations, but never written because it is a hook")
+			continue
+		}
+		if decl.zero {
+			fmt.Fprintln(w, "\tnever written because it is a zero function, its calls are replaced by the zero values")
+			continue
+		}
+		names := g.instanceNames(decl)
+		switch {
+		case len(names) == 1 && len(g.concreteTypes.Instance) > 1:
+			fmt.Fprintf(w, "\twritten once, shared by all instances: %s\n", names[0])
+		case len(names) < len(g.concreteTypes.Instance):
+			fmt.Fprintf(w, "\twritten %d times, shared by several instances: %s\n", len(names), strings.Join(names, ", "))
+		default:
+			fmt.Fprintf(w, "\twritten per instance: %s\n", strings.Join(names, ", "))
+		}
+	}
+	return nil
+}
+
+// explainReason returns the chain of identifiers which causes the dependency
+// of the declaration on the generic type with the given index. The reasons
+// always refer to dependencies which were found before, so there are no cycles.
+func (g *Generify) explainReason(decl *declWithDependency, index int) string {
+	r, ok := decl.reasons[index]
+	if !ok {
+		return "unknown"
+	}
+	switch {
+	case r.from == nil:
+		return fmt.Sprintf("uses %s at %v", g.genTypes[index], g.position(r.pos))
+	case r.method:
+		return fmt.Sprintf("has the method %s at %v, which %s", declName(r.from.decl), g.position(r.from.decl.Pos()), g.explainReason(r.from, index))
+	default:
+		return fmt.Sprintf("refers to %s at %v, which %s", declName(r.from.decl), g.position(r.pos), g.explainReason(r.from, index))
+	}
+}
+
+// instanceNames returns the distinct names the declaration gets for the instances
+func (g *Generify) instanceNames(decl *declWithDependency) []string {
+	var names []string
+	found := map[string]bool{}
+	for i, types := range g.concreteTypes.Instance {
+		name := g.concreteDeclName(decl, types, g.concreteTypes.Name(i))
+		if !found[name] {
+			found[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// concreteDeclName returns the name of the declaration for the given concrete types. Methods
+// keep their names, so the name of the renamed receiver type is included.
+func (g *Generify) concreteDeclName(decl *declWithDependency, types concrete.Types, name string) string {
+	switch d := decl.decl.(type) {
+	case *ast.FuncDecl:
+		if d.Recv == nil {
+			return concreteName(d.Name.Name, decl.usedTypes, types, name)
+		}
+		recv := receiverName(d)
+		if t := g.receiverDecl(d); t != nil && t.isGeneric() {
+			recv = concreteName(recv, t.usedTypes, types, name)
+		}
+		return fmt.Sprintf("(%s) %s", recv, d.Name.Name)
+	case *ast.GenDecl:
+		var names []string
+		for _, spec := range d.Specs {
+			switch s := spec.(type) {
+			case *ast.TypeSpec:
+				names = append(names, concreteName(s.Name.Name, decl.usedTypes, types, name))
+			case *ast.ValueSpec:
+				for _, n := range s.Names {
+					names = append(names, concreteName(n.Name, decl.usedTypes, types, name))
+				}
+			}
+		}
+		return strings.Join(names, ", ")
+	}
+	return ""
+}
+
+func (g *Generify) instanceTypes(types concrete.Types) string {
+	var assignments []string
+	for i, t := range types {
+		assignments = append(assignments, g.genTypes[i]+"="+t)
+	}
+	return strings.Join(assignments, ", ")
+}
+
+func (g *Generify) position(pos token.Pos) token.Position {
+	return g.fset.PositionFor(pos, false)
+}
+
+// declName returns a short description of the declaration like "func (List) Add"
+func declName(decl ast.Decl) string {
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		if d.Recv != nil {
+			return fmt.Sprintf("func (%s) %s", receiverName(d), d.Name.Name)
+		}
+		return "func " + d.Name.Name
+	case *ast.GenDecl:
+		var names []string
+		for _, spec := range d.Specs {
+			switch s := spec.(type) {
+			case *ast.TypeSpec:
+				names = append(names, s.Name.Name)
+			case *ast.ValueSpec:
+				for _, n := range s.Names {
+					names = append(names, n.Name)
+				}
+			}
+		}
+		return d.Tok.String() + " " + strings.Join(names, ", ")
+	}
+	return "declaration"
+}
+
+// receiverName returns the name of the receiver type of a method
+func receiverName(f *ast.FuncDecl) string {
+	if ident, ok := receiverType(f).(*ast.Ident); ok {
+		return ident.Name
+	}
+	return "?"
+}
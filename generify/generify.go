@@ -5,9 +5,16 @@ import (
 	"bytes"
 	"fmt"
 	"go/ast"
+	"go/parser"
 	"go/printer"
+	"go/scanner"
 	"go/token"
+	"go/types"
 	"io"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/hneemann/yagi/concrete"
@@ -16,17 +23,45 @@ import (
 
 var newline = []byte("\n\n")
 
-func checkNodeIsOffType(n ast.Node, kind ast.ObjKind) (*ast.Ident, bool) {
-	if ident, ok := n.(*ast.Ident); ok {
-		return ident, ident.Obj != nil && ident.Obj.Kind == kind
+type declWithDependency struct {
+	decl ast.Decl
+	// the template file the declaration belongs to
+	file     *ast.File
+	comments []*ast.CommentGroup
+	// a hook is renamed like all other declarations but never written
+	hook bool
+	// a zero function is never written, its calls are replaced by zero values
+	zero             bool
+	usedTypes        set.SetInt
+	writtenInstances []string
+	// the reasons of the dependencies on the generic types
+	reasons map[int]reason
+}
+
+// reason describes why a declaration depends on a generic type
+type reason struct {
+	// the identifier which causes the dependency
+	pos token.Pos
+	// the declaration which caused the dependency, nil if the generic type is used directly
+	from *declWithDependency
+	// set if the declaration is a type which depends on the generic type because of its method from
+	method bool
+}
+
+// dependsOn adds the given generic types to the dependencies of the declaration.
+// The reason is recorded for the types which are new.
+func (dwd *declWithDependency) dependsOn(types set.SetInt, r reason) {
+	for i := range types {
+		if !dwd.usedTypes.Has(i) {
+			dwd.usedTypes.Add(i)
+			dwd.reasons[i] = r
+		}
 	}
-	return nil, false
 }
 
-type declWithDependency struct {
-	decl             ast.Decl
-	usedTypes        set.SetInt
-	writtenInstances []concrete.Types
+// node returns the node to print, which includes the comments belonging to the declaration
+func (dwd declWithDependency) node() interface{} {
+	return &printer.CommentedNode{Node: dwd.decl, Comments: dwd.comments}
 }
 
 func (dwd declWithDependency) String() string {
@@ -36,99 +71,432 @@ func (dwd declWithDependency) String() string {
 	return fmt.Sprintf("used: %v\n%v", dwd.usedTypes, buffer)
 }
 
-func (dwd *declWithDependency) isAllreadyWritten(types concrete.Types) bool {
-	for _, wi := range dwd.writtenInstances {
-		same := 0
-		for i := range dwd.usedTypes {
-			if wi[i] == types[i] {
-				same++
-			}
+// isGeneric returns true if the declaration depends on a generic type
+func (dwd *declWithDependency) isGeneric() bool {
+	return len(dwd.usedTypes) > 0
+}
+
+// isStatic returns true if the declaration belongs to one of the given files
+// and is written only once because it does not depend on a generic type
+func (dwd *declWithDependency) isStatic(files []*ast.File) bool {
+	return !dwd.isGeneric() && dwd.isWritten() && !dwd.isImport() && dwd.isIn(files)
+}
+
+// isWritten returns false if the declaration is a hook or a zero function
+func (dwd *declWithDependency) isWritten() bool {
+	return !dwd.hook && !dwd.zero
+}
+
+// start returns the position of the first line written for the declaration
+func (dwd *declWithDependency) start() token.Pos {
+	start := dwd.decl.Pos()
+	for _, c := range dwd.comments {
+		if c.Pos() < start {
+			start = c.Pos()
 		}
-		if same == len(dwd.usedTypes) {
+	}
+	return start
+}
+
+func (dwd *declWithDependency) isImport() bool {
+	genDecl, ok := dwd.decl.(*ast.GenDecl)
+	return ok && genDecl.Tok == token.IMPORT
+}
+
+// isIn returns true if the declaration belongs to one of the given files
+func (dwd *declWithDependency) isIn(files []*ast.File) bool {
+	for _, f := range files {
+		if f == dwd.file {
+			return true
+		}
+	}
+	return false
+}
+
+func (dwd *declWithDependency) isAllreadyWritten(types concrete.Types, name string) bool {
+	suffix := concreteName("", dwd.usedTypes, types, name)
+	for _, wi := range dwd.writtenInstances {
+		if wi == suffix {
 			return true
 		}
 	}
-	dwd.writtenInstances = append(dwd.writtenInstances, types)
+	dwd.writtenInstances = append(dwd.writtenInstances, suffix)
 	return false
 }
 
 // Generify holds the data used to work on the ast
 type Generify struct {
-	// the parsed original template
-	file *ast.File
+	// PackagePath is the import path of the package the generated code belongs to.
+	// Fully qualified concrete types from this package are used without a qualifier.
+	PackagePath string
+	// LineDirectives enables the //line directives written in front of every
+	// declaration, which map the generated code back to the template.
+	LineDirectives bool
+	// OutputDir is the directory of the output file. The file names
+	// in the //line directives are relative to this directory.
+	OutputDir string
+	// Resolver is used to check the constraints of the generic types
+	// and to find the zero values of the concrete types.
+	// If it is nil, only types made of predeclared identifiers can be checked.
+	Resolver TypeResolver
+	// the file set used to parse the template
+	fset *token.FileSet
+	// the parsed original template files
+	files []*ast.File
+	// the type checked template package
+	pkg *types.Package
+	// the objects of the identifiers of the type checked template
+	info *types.Info
+	// the objects of the generic types
+	genObjects []types.Object
+	// the identifiers of fields and methods which are not resolved by their names
+	members map[*ast.Ident]bool
 	// the concrete types for which the code is generated
 	concreteTypes *concrete.Instances
+	// the imports needed by the concrete types
+	concreteImports []concrete.Import
 	// the name of the generic types
 	genTypes []string
+	// the constraints of the generic types
+	constraints []constraint
+	// the zero functions and the indices of the generic types they return
+	zeroFuncs map[types.Object]int
+	// the zero values of the concrete types returned by the zero functions
+	zeroValues map[string]string
 	// all the declarations from the template
 	genericDecls []*declWithDependency
 	// list of rename actions which are to perform on the ast to get a concrete type
 	renameActions []renameAction
+	// the renamed declaration names and the generic types they depend on
+	renamedNames map[string]set.SetInt
+	// the origins of the declarations written to each writer
+	origins map[io.Writer][]origin
+	// set if the template is already analysed
+	analysed bool
+	// the error found while analysing the template
+	analyseErr error
+	// set if the concrete types are already checked
+	prepared bool
+	// the error found while checking the concrete types
+	prepareErr error
+}
+
+// origin describes where a written declaration comes from
+type origin struct {
+	// the position of the declaration in the template
+	pos token.Pos
+	// the index of the instance or -1 if the declaration is not generic
+	instance int
 }
 
 type renameAction interface {
-	rename(concrete.Types)
+	rename(ct concrete.Types, name string)
+	// restore restores the original template
+	restore()
 }
 
 func (g *Generify) addRenameAction(renameAction renameAction) {
 	g.renameActions = append(g.renameActions, renameAction)
 }
 
-// New creates a new Generify instance
-func New(file *ast.File, concreteTypes *concrete.Instances) *Generify {
-	return &Generify{file: file, concreteTypes: concreteTypes}
+// errorf creates an error located at the given position of the template.
+// The error is a scanner.ErrorList like the errors returned by the parser.
+func (g *Generify) errorf(pos token.Pos, format string, a ...interface{}) error {
+	var list scanner.ErrorList
+	list.Add(g.fset.Position(pos), fmt.Sprintf(format, a...))
+	return list
+}
+
+// New creates a new Generify instance.
+// The given file set has to be the one used to parse the file.
+func New(fset *token.FileSet, file *ast.File, concreteTypes *concrete.Instances) *Generify {
+	return NewPackage(fset, []*ast.File{file}, concreteTypes)
+}
+
+// NewPackage creates a new Generify instance for a template which consists of several files.
+// All files have to belong to the same package. The given file set has to be the one used
+// to parse the files. The files may contain test files, which are only written by DoTests.
+func NewPackage(fset *token.FileSet, files []*ast.File, concreteTypes *concrete.Instances) *Generify {
+	return &Generify{fset: fset, files: files, concreteTypes: concreteTypes,
+		renamedNames: map[string]set.SetInt{}, origins: map[io.Writer][]origin{}}
 }
 
 // Do creates a concrete ast from the generic one and writes it to the given io.Writer
 func (g *Generify) Do(packageName string, w io.Writer) error {
-	var decls []ast.Decl
-	g.genTypes, decls = findGenerics(g.file)
-	if len(g.genTypes) == 0 {
-		return fmt.Errorf("no generic types found")
+	err := g.prepare()
+	if err != nil {
+		return err
+	}
+	return g.write(packageName, g.selectFiles(false), w)
+}
+
+// DoTests creates the concrete tests from the test files of the template and writes them
+// to the given io.Writer. Test functions depending on a generic type are renamed like
+// all other functions, so every instance gets its own tests. A function marked with a
+// "hook" comment is not written. Instead, every instance calls its own version of this
+// function which has to be implemented by hand, e.g. to provide the test values.
+func (g *Generify) DoTests(packageName string, w io.Writer) error {
+	err := g.prepare()
+	if err != nil {
+		return err
+	}
+	tests := g.selectFiles(true)
+	if len(tests) == 0 {
+		return fmt.Errorf("no test files found")
+	}
+	return g.write(packageName, tests, w)
+}
+
+// HasTests returns true if the template contains test files
+func (g *Generify) HasTests() bool {
+	return len(g.selectFiles(true)) > 0
+}
+
+func (g *Generify) selectFiles(tests bool) []*ast.File {
+	var files []*ast.File
+	for _, f := range g.files {
+		if g.isTestFile(f) == tests {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+func (g *Generify) isTestFile(f *ast.File) bool {
+	return strings.HasSuffix(g.fset.Position(f.Package).Filename, "_test.go")
+}
+
+// DoPerFile creates a concrete ast from the generic one like Do, but writes the
+// declarations of each template file to its own io.Writer. The function out is
+// called with the name of each template file and returns the writer to use.
+func (g *Generify) DoPerFile(packageName string, out func(templateFile string) (io.Writer, error)) error {
+	err := g.prepare()
+	if err != nil {
+		return err
+	}
+	for _, f := range g.files {
+		w, err := out(g.fset.Position(f.Package).Filename)
+		if err != nil {
+			return err
+		}
+		err = g.write(packageName, []*ast.File{f}, w)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Origin maps a position in the code written to w back to the template. The source is the
+// written code, which may have been formatted by go imports in the meantime. Origin returns the
+// position in the template and the index of the instance the code at this position was created
+// for, or -1 if the code does not depend on a generic type. If the position can not be mapped,
+// ok is false.
+func (g *Generify) Origin(w io.Writer, source []byte, pos token.Position) (templatePos token.Position, instance int, ok bool) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", source, 0)
+	if err != nil {
+		return token.Position{}, 0, false
+	}
+
+	origins := g.origins[w]
+	index := 0
+	for _, d := range file.Decls {
+		if gd, ok := d.(*ast.GenDecl); ok && gd.Tok == token.IMPORT {
+			continue
+		}
+		if index >= len(origins) {
+			break
+		}
+		// the //line directives are ignored
+		start := fset.PositionFor(d.Pos(), false).Line
+		if start <= pos.Line && pos.Line <= fset.PositionFor(d.End(), false).Line {
+			o := origins[index]
+			templatePos = g.fset.Position(o.pos)
+			templatePos.Line += pos.Line - start
+			templatePos.Column = pos.Column
+			return templatePos, o.instance, true
+		}
+		index++
+	}
+	return token.Position{}, 0, false
+}
+
+// prepare analyses the template and checks the concrete types
+func (g *Generify) prepare() error {
+	if g.prepared {
+		return g.prepareErr
+	}
+	g.prepared = true
+	g.prepareErr = g.prepareTypes()
+	return g.prepareErr
+}
+
+// prepareTypes checks the concrete types, resolves their imports and zero values
+func (g *Generify) prepareTypes() error {
+	err := g.analyse()
+	if err != nil {
+		return err
 	}
 	if len(g.genTypes) != len(g.concreteTypes.Instance[0]) {
 		return fmt.Errorf("there are %d generic types but %d concrete types", len(g.genTypes), len(g.concreteTypes.Instance[0]))
 	}
 
-	removeCommentsFrom(decls)
+	g.resolveConcreteImports()
 
-	decls = splitDeclsToUngroupedDecls(decls)
+	err = g.checkConstraints()
+	if err != nil {
+		return err
+	}
+	g.resolveZeroValues()
+	return nil
+}
 
-	g.genericDecls = g.inspectAllDeclsForDependencies(decls)
+// analyse finds the generic types and creates the rename actions.
+// The concrete types are not needed for this.
+func (g *Generify) analyse() error {
+	if g.analysed {
+		return g.analyseErr
+	}
+	g.analysed = true
+	g.analyseErr = g.analyseTemplate()
+	return g.analyseErr
+}
 
-	g.checkMethodDependencies()
+func (g *Generify) analyseTemplate() error {
+	g.typeCheckTemplate()
 
-	g.renameStructsAndVars()
+	fileDecls := make([][]ast.Decl, len(g.files))
+	for i, f := range g.files {
+		fileDecls[i] = g.findGenerics(f)
+	}
+	if len(g.genTypes) == 0 {
+		return fmt.Errorf("no generic types found")
+	}
 
-	g.renameFunctions()
+	for i, f := range g.files {
+		decls := splitDeclsToUngroupedDecls(fileDecls[i])
+		g.genericDecls = append(g.genericDecls, g.inspectAllDeclsForDependencies(f, decls)...)
+	}
+
+	err := g.checkReceivers()
+	if err != nil {
+		return err
+	}
+	err = g.findZeroCalls()
+	if err != nil {
+		return err
+	}
+
+	// the dependencies are propagated until they do not change anymore,
+	// so the result does not depend on the order of the declarations
+	simpleRenames := len(g.renameActions)
+	for {
+		dependencies := g.dependencyCount()
+		g.renameActions = g.renameActions[:simpleRenames]
+
+		g.checkMethodDependencies()
+
+		g.renameStructsAndVars()
+
+		g.renameFunctions()
+
+		if g.dependencyCount() == dependencies {
+			break
+		}
+	}
+
+	err = g.checkMethodReceivers()
+	if err != nil {
+		return err
+	}
 
-	file := ast.File{Name: g.file.Name, Decls: g.staticDecls(), Scope: g.file.Scope, Imports: g.file.Imports}
+	g.renameComments()
+
+	return nil
+}
+
+// GenericTypes returns the names of the generic types of the template
+func (g *Generify) GenericTypes() ([]string, error) {
+	err := g.analyse()
+	if err != nil {
+		return nil, err
+	}
+	return g.genTypes, nil
+}
+
+// RenamedNames returns the names of all declarations which are renamed for every
+// instance, together with the indices of the generic types they depend on.
+func (g *Generify) RenamedNames() (map[string][]int, error) {
+	err := g.analyse()
+	if err != nil {
+		return nil, err
+	}
+	names := map[string][]int{}
+	for name, usedIndices := range g.renamedNames {
+		indices := usedIndices.Items()
+		sort.Ints(indices)
+		names[name] = indices
+	}
+	return names, nil
+}
+
+// write writes the declarations of the given template files
+func (g *Generify) write(packageName string, files []*ast.File, w io.Writer) error {
+	if len(files) == 0 {
+		return fmt.Errorf("no template files found")
+	}
+	name := files[0].Name
 	if packageName != "" {
-		file.Name.Name = packageName
+		name = &ast.Ident{NamePos: name.NamePos, Name: packageName}
 	}
+	file := ast.File{Package: files[0].Package, Name: name, Decls: g.staticDecls(files)}
+	for _, d := range file.Decls {
+		g.origins[w] = append(g.origins[w], origin{pos: d.Pos(), instance: -1})
+	}
+	comments := g.staticComments(files)
+	if g.LineDirectives {
+		// the static declarations are written one by one, each with its own directive
+		file.Decls = nil
+		comments = nil
+	}
+	g.addImports(&file, files)
 
-	fset := token.NewFileSet()
-	err := printer.Fprint(w, fset, &file)
+	err := printer.Fprint(w, g.fset, &printer.CommentedNode{Node: &file, Comments: comments})
 	if err != nil {
 		return err
 	}
 	w.Write(newline)
 
-	for _, types := range g.concreteTypes.Instance {
+	if g.LineDirectives {
+		for _, decl := range g.genericDecls {
+			if decl.isStatic(files) {
+				err := g.writeDecl(w, decl)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	// the template is restored, so it can be used again
+	defer g.restore()
+	for index, types := range g.concreteTypes.Instance {
+		name := g.concreteTypes.Name(index)
 
 		// rename all identifiers
 		for _, ra := range g.renameActions {
-			ra.rename(types)
+			ra.rename(types, name)
 		}
 
 		// write the renamed ast
 		for _, decl := range g.genericDecls {
-			if len(decl.usedTypes) > 0 && !decl.isAllreadyWritten(types) {
-				err := printer.Fprint(w, fset, decl.decl)
+			if decl.isGeneric() && decl.isWritten() && decl.isIn(files) && !decl.isAllreadyWritten(types, name) {
+				g.origins[w] = append(g.origins[w], origin{pos: decl.decl.Pos(), instance: index})
+				err := g.writeDecl(w, decl)
 				if err != nil {
 					return err
 				}
-				w.Write(newline)
 			}
 		}
 	}
@@ -136,16 +504,165 @@ func (g *Generify) Do(packageName string, w io.Writer) error {
 	return nil
 }
 
-func (g *Generify) staticDecls() []ast.Decl {
+// writeDecl writes a single declaration, preceded by a //line directive if enabled
+func (g *Generify) writeDecl(w io.Writer, decl *declWithDependency) error {
+	if g.LineDirectives {
+		// The directive is separated by an empty line so that gofmt does not
+		// merge it into the doc comment. So it refers to the line above.
+		pos := g.fset.Position(decl.start())
+		fmt.Fprintf(w, "//line %s:%d\n\n", g.lineFileName(pos.Filename), pos.Line-1)
+	}
+	err := printer.Fprint(w, g.fset, decl.node())
+	if err != nil {
+		return err
+	}
+	w.Write(newline)
+	return nil
+}
+
+// lineFileName returns the name of the template file relative to the output directory
+func (g *Generify) lineFileName(name string) string {
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return name
+	}
+	dir, err := filepath.Abs(g.OutputDir)
+	if err != nil {
+		return abs
+	}
+	rel, err := filepath.Rel(dir, abs)
+	if err != nil {
+		return abs
+	}
+	return filepath.ToSlash(rel)
+}
+
+// restore undoes all renamings
+func (g *Generify) restore() {
+	for _, ra := range g.renameActions {
+		ra.restore()
+	}
+}
+
+func (g *Generify) staticDecls(files []*ast.File) []ast.Decl {
 	decls := []ast.Decl{}
 	for _, d := range g.genericDecls {
-		if len(d.usedTypes) == 0 {
+		if d.isStatic(files) {
 			decls = append(decls, d.decl)
 		}
 	}
 	return decls
 }
 
+func (g *Generify) staticComments(files []*ast.File) []*ast.CommentGroup {
+	var comments []*ast.CommentGroup
+	for _, d := range g.genericDecls {
+		if !d.isGeneric() && d.isWritten() && d.isIn(files) {
+			comments = append(comments, d.comments...)
+		}
+	}
+	return comments
+}
+
+// resolveConcreteImports determines the names of the packages needed by the fully qualified
+// concrete types. A package which is also imported by the template uses the same name, and a
+// package whose name clashes with a name used by the template gets an alias.
+func (g *Generify) resolveConcreteImports() {
+	used := map[string]bool{}
+	for _, name := range g.pkg.Scope().Names() {
+		used[name] = true
+	}
+	for _, f := range g.files {
+		for _, spec := range f.Imports {
+			used[importName(spec)] = true
+		}
+	}
+
+	names := map[string]string{}
+	for _, imp := range g.concreteTypes.Imports {
+		if imp.Path == g.PackagePath {
+			names[imp.Name] = ""
+		} else if spec := g.templateImport(imp.Path); spec != nil {
+			names[imp.Name] = importName(spec)
+		} else {
+			name := imp.Name
+			for n := 2; used[name]; n++ {
+				name = imp.Name + strconv.Itoa(n)
+			}
+			used[name] = true
+			names[imp.Name] = name
+		}
+	}
+	g.concreteTypes.RenameImports(names)
+	g.concreteImports = g.concreteTypes.Imports
+}
+
+// addImports adds the imports of the given template files and the
+// imports needed by the concrete types to the given file
+func (g *Generify) addImports(file *ast.File, files []*ast.File) {
+	var specs []ast.Spec
+	contains := func(spec *ast.ImportSpec) bool {
+		for _, s := range specs {
+			if s.(*ast.ImportSpec).Path.Value == spec.Path.Value && importName(s.(*ast.ImportSpec)) == importName(spec) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, f := range files {
+		for _, spec := range f.Imports {
+			if !contains(spec) {
+				specs = append(specs, spec)
+			}
+		}
+	}
+	if g.hasGenericDecls(files) {
+		for _, imp := range g.concreteImports {
+			if spec := imp.Spec(); !contains(spec) {
+				specs = append(specs, spec)
+			}
+		}
+	}
+
+	if len(specs) > 0 {
+		for _, spec := range specs {
+			file.Imports = append(file.Imports, spec.(*ast.ImportSpec))
+		}
+		file.Decls = append([]ast.Decl{&ast.GenDecl{TokPos: file.Name.End(), Tok: token.IMPORT, Specs: specs}}, file.Decls...)
+	}
+}
+
+func (g *Generify) hasGenericDecls(files []*ast.File) bool {
+	for _, d := range g.genericDecls {
+		if d.isGeneric() && d.isIn(files) {
+			return true
+		}
+	}
+	return false
+}
+
+// templateImport returns the import spec of the given package if it is imported by the template
+func (g *Generify) templateImport(importPath string) *ast.ImportSpec {
+	for _, f := range g.files {
+		for _, spec := range f.Imports {
+			if p, _ := strconv.Unquote(spec.Path.Value); p == importPath {
+				return spec
+			}
+		}
+	}
+	return nil
+}
+
+// importName returns the name which is used to access an imported package
+func importName(spec *ast.ImportSpec) string {
+	if spec.Name != nil {
+		return spec.Name.Name
+	}
+	p, _ := strconv.Unquote(spec.Path.Value)
+	return concrete.PackageName(p)
+}
+
 type declVisitor interface {
 	ast.Visitor
 	finalize(*declWithDependency)
@@ -158,23 +675,22 @@ func (g *Generify) walk(v declVisitor) {
 	}
 }
 
-// find type declarations which are marked with the "generic" comment
-// returns this type names and the remaining declarations
-func findGenerics(file *ast.File) ([]string, []ast.Decl) {
-	var genTypes []string
-
+// find type declarations which are marked with the "generic" comment,
+// stores this type names and their constraints and returns the remaining declarations
+func (g *Generify) findGenerics(file *ast.File) []ast.Decl {
 	newDecls := []ast.Decl{}
 	for _, d := range file.Decls {
 		remove := false
 		if gd, ok := d.(*ast.GenDecl); ok {
 			if gd.Tok == token.TYPE {
-				if strings.TrimSpace(gd.Doc.Text()) == "generic" {
-					if len(gd.Specs) == 1 {
-						if ts, ok := gd.Specs[0].(*ast.TypeSpec); ok {
-							// pick the generic type
-							genTypes = append(genTypes, ts.Name.Name)
-							remove = true
-						}
+				isGeneric, constraints := g.constraintsOf(gd, len(g.genTypes))
+				if isGeneric && len(gd.Specs) == 1 {
+					if ts, ok := gd.Specs[0].(*ast.TypeSpec); ok {
+						// pick the generic type
+						g.genTypes = append(g.genTypes, ts.Name.Name)
+						g.genObjects = append(g.genObjects, g.objectOf(ts.Name))
+						g.constraints = append(g.constraints, constraints...)
+						remove = true
 					}
 				}
 			}
@@ -184,39 +700,34 @@ func findGenerics(file *ast.File) ([]string, []ast.Decl) {
 		}
 	}
 
-	return genTypes, newDecls
+	return newDecls
 }
 
-func removeCommentsFrom(decls []ast.Decl) {
-	for _, decl := range decls {
-		ast.Inspect(decl, func(n ast.Node) bool {
-			switch d := n.(type) {
-			case *ast.GenDecl:
-				d.Doc = nil
-				for _, spec := range d.Specs {
-					switch s := spec.(type) {
-					case *ast.ImportSpec:
-						s.Doc = nil
-						s.Comment = nil
-					case *ast.ValueSpec:
-						s.Doc = nil
-						s.Comment = nil
-					case *ast.TypeSpec:
-						s.Doc = nil
-						s.Comment = nil
-					}
-				}
-			case *ast.FuncDecl:
-				d.Doc = nil
-			case *ast.StructType:
-				for _, field := range d.Fields.List {
-					field.Doc = nil
-					field.Comment = nil
-				}
-			}
-			return true
-		})
+// commentsOf returns all comments of the template which belong to the given declaration.
+// These are the doc comment, all comments inside the declaration and
+// a comment which follows the declaration on the same line.
+func (g *Generify) commentsOf(file *ast.File, decl ast.Decl) []*ast.CommentGroup {
+	from := decl.Pos()
+	switch d := decl.(type) {
+	case *ast.GenDecl:
+		if d.Doc != nil {
+			from = d.Doc.Pos()
+		}
+	case *ast.FuncDecl:
+		if d.Doc != nil {
+			from = d.Doc.Pos()
+		}
+	}
+	to := decl.End()
+	lastLine := g.fset.Position(to).Line
+
+	var comments []*ast.CommentGroup
+	for _, c := range file.Comments {
+		if c.Pos() >= from && (c.End() <= to || g.fset.Position(c.Pos()).Line == lastLine) {
+			comments = append(comments, c)
+		}
 	}
+	return comments
 }
 
 func splitDeclsToUngroupedDecls(decls []ast.Decl) []ast.Decl {
@@ -224,10 +735,17 @@ func splitDeclsToUngroupedDecls(decls []ast.Decl) []ast.Decl {
 	for _, decl := range decls {
 		copyDecl := true
 		if genDecl, ok := decl.(*ast.GenDecl); ok {
-			if len(genDecl.Specs) > 1 {
+			if len(genDecl.Specs) == 0 {
+				// empty groups like "var ()" declare nothing
+				continue
+			}
+			if len(genDecl.Specs) > 1 && !isConstBlock(genDecl) {
 				copyDecl = false
-				for _, spec := range genDecl.Specs {
-					gd := ast.GenDecl{Tok: genDecl.Tok, Specs: []ast.Spec{spec}}
+				for i, spec := range genDecl.Specs {
+					gd := ast.GenDecl{Doc: specDoc(spec), TokPos: spec.Pos(), Tok: genDecl.Tok, Specs: []ast.Spec{spec}}
+					if i == 0 && gd.Doc == nil {
+						gd.Doc = genDecl.Doc
+					}
 					newDecls = append(newDecls, &gd)
 				}
 			}
@@ -239,81 +757,148 @@ func splitDeclsToUngroupedDecls(decls []ast.Decl) []ast.Decl {
 	return newDecls
 }
 
+// isConstBlock checks if the declaration is a const block which has to be kept as a unit,
+// because it uses iota or the implicit repetition of the previous expression list.
+func isConstBlock(genDecl *ast.GenDecl) bool {
+	if genDecl.Tok != token.CONST {
+		return false
+	}
+	for _, spec := range genDecl.Specs {
+		if vs, ok := spec.(*ast.ValueSpec); ok {
+			if len(vs.Values) == 0 {
+				return true
+			}
+			for _, v := range vs.Values {
+				usesIota := false
+				ast.Inspect(v, func(n ast.Node) bool {
+					if id, ok := n.(*ast.Ident); ok && id.Name == "iota" {
+						usesIota = true
+					}
+					return !usesIota
+				})
+				if usesIota {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// isHook checks if the declaration is a function marked with the "hook" comment
+func isHook(decl ast.Decl) bool {
+	funcDecl, ok := decl.(*ast.FuncDecl)
+	return ok && strings.TrimSpace(funcDecl.Doc.Text()) == "hook"
+}
+
+func specDoc(spec ast.Spec) *ast.CommentGroup {
+	switch s := spec.(type) {
+	case *ast.ValueSpec:
+		return s.Doc
+	case *ast.TypeSpec:
+		return s.Doc
+	case *ast.ImportSpec:
+		return s.Doc
+	}
+	return nil
+}
+
 type simpleVisitor struct {
 	g          *Generify
 	foundTypes set.SetInt
+	reasons    map[int]reason
 }
 
 func newSimpleVisitor(g *Generify) *simpleVisitor {
-	return &simpleVisitor{g, make(set.SetInt)}
+	return &simpleVisitor{g, make(set.SetInt), map[int]reason{}}
 }
 
 type simpleRename struct {
+	g        *Generify
 	ident    *ast.Ident
 	genIndex int
 }
 
-func (ir simpleRename) rename(t concrete.Types) {
+func (ir simpleRename) rename(t concrete.Types, _ string) {
 	ir.ident.Name = t[ir.genIndex]
 }
 
+func (ir simpleRename) restore() {
+	ir.ident.Name = ir.g.genTypes[ir.genIndex]
+}
+
 func (sv *simpleVisitor) Visit(n ast.Node) ast.Visitor {
-	if id, ok := checkNodeIsOffType(n, ast.Typ); ok {
-		for i, gen := range sv.g.genTypes {
-			if id.Name == gen {
-				sv.g.addRenameAction(simpleRename{id, i})
-				sv.foundTypes[i] = struct{}{}
+	if id, ok := n.(*ast.Ident); ok {
+		obj := sv.g.objectOf(id)
+		for i, gen := range sv.g.genObjects {
+			if obj != nil && obj == gen {
+				sv.g.addRenameAction(simpleRename{sv.g, id, i})
+				if !sv.foundTypes.Has(i) {
+					sv.foundTypes.Add(i)
+					sv.reasons[i] = reason{pos: id.Pos()}
+				}
 			}
 		}
 	}
 	return sv
 }
 
-func (g *Generify) inspectAllDeclsForDependencies(decls []ast.Decl) []*declWithDependency {
+func (g *Generify) inspectAllDeclsForDependencies(file *ast.File, decls []ast.Decl) []*declWithDependency {
 	newDecls := []*declWithDependency{}
 	for _, decl := range decls {
 		sv := newSimpleVisitor(g)
 		ast.Walk(sv, decl)
-		newDecls = append(newDecls, &declWithDependency{decl, sv.foundTypes, nil})
+		newDecls = append(newDecls, &declWithDependency{decl, file, g.commentsOf(file, decl), isHook(decl), isZero(decl), sv.foundTypes, nil, sv.reasons})
 	}
 	return newDecls
 }
 
-// add more dependencies to the given struct
-func (g *Generify) structDependsOn(structName string, types set.SetInt) {
+// dependencyCount returns the number of dependencies of all declarations
+func (g *Generify) dependencyCount() int {
+	count := 0
 	for _, decl := range g.genericDecls {
-		if genDecl, ok := decl.decl.(*ast.GenDecl); ok {
-			spec := genDecl.Specs[0]
-			if typeSpec, ok := spec.(*ast.TypeSpec); ok {
-				if typeSpec.Name.Name == structName {
-					decl.usedTypes.AddAll(types)
+		count += len(decl.usedTypes)
+	}
+	return count
+}
+
+// checkReceivers returns an error if a method is declared on a generic type,
+// because the generic type is replaced by concrete types which can not have methods.
+func (g *Generify) checkReceivers() error {
+	for _, decl := range g.genericDecls {
+		if funcDecl, ok := decl.decl.(*ast.FuncDecl); ok && funcDecl.Recv != nil {
+			recv := receiverType(funcDecl)
+			for i, gen := range g.genObjects {
+				if id, ok := recv.(*ast.Ident); ok && gen != nil && g.objectOf(id) == gen {
+					return g.errorf(recv.Pos(), "the method %s can not be declared on the generic type %s", funcDecl.Name.Name, g.genTypes[i])
 				}
 			}
 		}
 	}
+	return nil
+}
+
+// checkMethodReceivers returns an error if a method depends on a generic type but
+// its receiver type is not declared in the template, so the receiver can not be renamed.
+// In this case the method would be written several times with the same name.
+func (g *Generify) checkMethodReceivers() error {
+	for _, decl := range g.genericDecls {
+		if funcDecl, ok := decl.decl.(*ast.FuncDecl); ok && funcDecl.Recv != nil && decl.isGeneric() {
+			if g.receiverDecl(funcDecl) == nil {
+				return g.errorf(funcDecl.Recv.Pos(), "the method %s depends on a generic type but its receiver type %s is not declared in the template", funcDecl.Name.Name, receiverName(funcDecl))
+			}
+		}
+	}
+	return nil
 }
 
 // checks for methods depending on generic types to handle the case
 // if the struct itself does not.
 func (g *Generify) checkMethodDependencies() {
 	for _, decl := range g.genericDecls {
-		if funcDecl, ok := decl.decl.(*ast.FuncDecl); ok {
-			if funcDecl.Recv != nil { // is method
-				field := funcDecl.Recv.List[0]
-				obj := field.Names[0].Obj
-				if obj.Kind == ast.Var {
-					if field, ok := obj.Decl.(*ast.Field); ok {
-						exp := field.Type
-						if star, ok := exp.(*ast.StarExpr); ok { // get inner if pointer receiver
-							exp = star.X
-						}
-						if ident, ok := exp.(*ast.Ident); ok {
-							g.structDependsOn(ident.Name, decl.usedTypes)
-						} else {
-							panic("syntax error")
-						}
-					}
-				}
+		if funcDecl, ok := decl.decl.(*ast.FuncDecl); ok && funcDecl.Recv != nil {
+			if recv := g.receiverDecl(funcDecl); recv != nil {
+				recv.dependsOn(decl.usedTypes, reason{pos: funcDecl.Recv.Pos(), from: decl, method: true})
 			}
 		}
 	}
@@ -321,25 +906,46 @@ func (g *Generify) checkMethodDependencies() {
 
 type renameVisitor struct {
 	g           *Generify
-	kind        ast.ObjKind
 	origName    string
 	usedIndices set.SetInt
-	wasActive   bool
+	// the declaration of the renamed identifier
+	decl *declWithDependency
+	// the object of the renamed identifier
+	obj types.Object
+	// the position of the first renamed identifier in the visited declaration
+	pos       token.Pos
+	wasActive bool
 }
 
 func (rv *renameVisitor) Visit(n ast.Node) ast.Visitor {
-	if id, ok := checkNodeIsOffType(n, rv.kind); ok {
-		if id.Name == rv.origName {
-			rv.g.addRenameAction(multiRename{rv.origName, id, rv.usedIndices})
-			rv.wasActive = true
+	if id, ok := rv.refersTo(n); ok {
+		rv.g.addRenameAction(multiRename{rv.origName, id, rv.usedIndices})
+		rv.g.renamedNames[rv.origName] = rv.usedIndices
+		if !rv.wasActive {
+			rv.pos = id.Pos()
 		}
+		rv.wasActive = true
 	}
 	return rv
 }
 
+// refersTo returns true if the node is an identifier which refers to the renamed declaration
+func (rv *renameVisitor) refersTo(n ast.Node) (*ast.Ident, bool) {
+	id, ok := n.(*ast.Ident)
+	if !ok || rv.obj == nil {
+		return nil, false
+	}
+	obj := rv.g.objectOf(id)
+	if v, ok := obj.(*types.Var); ok && v.Embedded() {
+		// the name of an embedded field is the name of its type
+		obj = typeNameOf(v.Type())
+	}
+	return id, obj == rv.obj
+}
+
 func (rv *renameVisitor) finalize(d *declWithDependency) {
 	if rv.wasActive {
-		d.usedTypes.AddAll(rv.usedIndices)
+		d.dependsOn(rv.usedIndices, reason{pos: rv.pos, from: rv.decl})
 	}
 	rv.wasActive = false
 }
@@ -350,28 +956,42 @@ type multiRename struct {
 	usedIndices set.SetInt
 }
 
-func (mr multiRename) rename(ct concrete.Types) {
+func (mr multiRename) rename(ct concrete.Types, name string) {
 	// ToDo: this operation is done over and over again!
-	n := mr.origName
+	mr.ident.Name = concreteName(mr.origName, mr.usedIndices, ct, name)
+}
+
+func (mr multiRename) restore() {
+	mr.ident.Name = mr.origName
+}
+
+// concreteName creates the name of a renamed declaration.
+// If the instance is named and the declaration depends on all
+// generic types, the instance name is used as suffix.
+func concreteName(origName string, usedIndices set.SetInt, ct concrete.Types, name string) string {
+	if name != "" && len(usedIndices) == len(ct) {
+		return origName + name
+	}
+	n := origName
 	for i, conName := range ct {
-		if _, ok := mr.usedIndices[i]; ok {
-			conName = strings.Replace(conName, "*", "P", -1)
-			conName = strings.Replace(conName, ".", "", -1)
-			n += strings.Title(conName)
+		if _, ok := usedIndices[i]; ok {
+			n += concrete.Suffix(conName)
 		}
 	}
-	mr.ident.Name = n
+	return n
 }
 
 func (g *Generify) renameStructsAndVars() {
 	for _, decl := range g.genericDecls {
 		if genDecl, ok := decl.decl.(*ast.GenDecl); ok {
-			switch spec := genDecl.Specs[0].(type) {
-			case *ast.TypeSpec:
-				g.walk(&renameVisitor{g, ast.Typ, spec.Name.Name, decl.usedTypes, false})
-			case *ast.ValueSpec:
-				for _, name := range spec.Names {
-					g.walk(&renameVisitor{g, ast.Var, name.Name, decl.usedTypes, false})
+			for _, spec := range genDecl.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					g.walk(&renameVisitor{g: g, origName: spec.Name.Name, usedIndices: decl.usedTypes, decl: decl, obj: g.objectOf(spec.Name)})
+				case *ast.ValueSpec:
+					for _, name := range spec.Names {
+						g.walk(&renameVisitor{g: g, origName: name.Name, usedIndices: decl.usedTypes, decl: decl, obj: g.objectOf(name)})
+					}
 				}
 			}
 		}
@@ -381,7 +1001,94 @@ func (g *Generify) renameStructsAndVars() {
 func (g *Generify) renameFunctions() {
 	for _, decl := range g.genericDecls {
 		if funcDecl, ok := decl.decl.(*ast.FuncDecl); ok {
-			g.walk(&renameVisitor{g, ast.Fun, funcDecl.Name.Name, decl.usedTypes, false})
+			if g.isTestFile(decl.file) && funcDecl.Recv == nil && strings.HasPrefix(funcDecl.Name.Name, "Example") {
+				g.addRenameAction(exampleRename{g, funcDecl.Name, funcDecl.Name.Name, decl.usedTypes})
+				continue
+			}
+			if funcDecl.Recv != nil || decl.zero {
+				// methods are not renamed, their receiver types are,
+				// and the calls of zero functions are replaced
+				continue
+			}
+			g.walk(&renameVisitor{g: g, origName: funcDecl.Name.Name, usedIndices: decl.usedTypes, decl: decl, obj: g.objectOf(funcDecl.Name)})
+		}
+	}
+}
+
+var identInComment = regexp.MustCompile(`[\pL_][\pL\pN_]*`)
+
+type commentRename struct {
+	g       *Generify
+	comment *ast.Comment
+	text    string
+	// the name of the method the comment belongs to, which is not renamed
+	method string
+}
+
+func (cr commentRename) rename(ct concrete.Types, name string) {
+	cr.comment.Text = identInComment.ReplaceAllStringFunc(cr.text, func(word string) string {
+		if word == cr.method {
+			return word
+		}
+		for i, gen := range cr.g.genTypes {
+			if word == gen {
+				return ct[i]
+			}
+		}
+		if usedIndices, ok := cr.g.renamedNames[word]; ok {
+			return concreteName(word, usedIndices, ct, name)
+		}
+		return word
+	})
+}
+
+func (cr commentRename) restore() {
+	cr.comment.Text = cr.text
+}
+
+// renames the generic types and the renamed declarations
+// if they are mentioned in the comments of a declaration
+func (g *Generify) renameComments() {
+	for _, decl := range g.genericDecls {
+		if decl.isGeneric() {
+			method := ""
+			if funcDecl, ok := decl.decl.(*ast.FuncDecl); ok && funcDecl.Recv != nil {
+				method = funcDecl.Name.Name
+			}
+			for _, cg := range decl.comments {
+				for _, c := range cg.List {
+					g.addRenameAction(commentRename{g, c, c.Text, method})
+				}
+			}
 		}
 	}
 }
+
+// exampleRename renames an example function in a way that the go tool
+// still recognizes the identifier the example belongs to, e.g.
+// ExampleList_Add becomes ExampleListInt64_Add. If the example does not
+// belong to a renamed identifier, a suffix like _int64 is added.
+type exampleRename struct {
+	g           *Generify
+	ident       *ast.Ident
+	origName    string
+	usedIndices set.SetInt
+}
+
+func (er exampleRename) rename(ct concrete.Types, name string) {
+	if len(er.usedIndices) == 0 {
+		er.ident.Name = er.origName
+		return
+	}
+	parts := strings.SplitN(strings.TrimPrefix(er.origName, "Example"), "_", 2)
+	if usedIndices, ok := er.g.renamedNames[parts[0]]; ok && len(usedIndices) > 0 {
+		parts[0] = concreteName(parts[0], usedIndices, ct, name)
+		er.ident.Name = "Example" + strings.Join(parts, "_")
+	} else {
+		er.ident.Name = er.origName + "_" + strings.ToLower(concreteName("", er.usedIndices, ct, name))
+	}
+}
+
+func (er exampleRename) restore() {
+	er.ident.Name = er.origName
+}
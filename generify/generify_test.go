@@ -2,10 +2,13 @@ package generify
 
 import (
 	"bytes"
+	"fmt"
 	"go/ast"
 	"go/parser"
 	"go/printer"
 	"go/token"
+	"go/types"
+	"io"
 	"strings"
 	"testing"
 
@@ -15,10 +18,15 @@ import (
 )
 
 func getFile(t *testing.T, code string) *ast.File {
+	_, file := parseFile(t, code)
+	return file
+}
+
+func parseFile(t *testing.T, code string) (*token.FileSet, *ast.File) {
 	fset := token.NewFileSet()
 	file, err := parser.ParseFile(fset, "", code, parser.ParseComments)
 	assert.NoError(t, err)
-	return file
+	return fset, file
 }
 
 func getSource(t *testing.T, n ast.Node) string {
@@ -55,13 +63,60 @@ var (
 	assert.Equal(t, 1, strings.Count(s, "var b int\n"))
 }
 
+func TestSplitDeclsToUngroupedDecls3(t *testing.T) {
+	f := getFile(t, `package test
+const (
+	a = 1
+	b = 2
+)
+const (
+	c = iota
+	d
+)
+const (
+	e, f = 1 << iota, 2
+)`)
+	decls := splitDeclsToUngroupedDecls(f.Decls)
+	assert.Equal(t, 4, len(decls))
+	s := getSource(t, &ast.File{Name: &ast.Ident{Name: "a"}, Decls: decls})
+	assert.Equal(t, 1, strings.Count(s, "const a = 1\n"))
+	assert.Equal(t, 1, strings.Count(s, "const b = 2\n"))
+	assert.Equal(t, 1, strings.Count(s, "const (\n\tc\t= iota\n\td\n)\n"), s)
+}
+
+func TestEmptyGroups(t *testing.T) {
+	out := gen(t, `package test
+
+import ()
+
+//generic
+type ITEM int
+
+var ()
+
+const ()
+
+type ()
+
+type List struct {
+	items []ITEM
+}
+
+func (l *List) Len() int {
+	return len(l.items)
+}`, "int32")
+
+	assert.Contains(t, out, "func (l *ListInt32) Len() int {\n")
+	assert.Equal(t, 0, strings.Count(out, "()\n"), out)
+}
+
 func gen(t *testing.T, code string, types string) string {
-	file := getFile(t, code)
+	fset, file := parseFile(t, code)
 
 	c, err := concrete.New(types)
 	assert.NoError(t, err)
 
-	gen := New(file, c)
+	gen := New(fset, file, c)
 	assert.NoError(t, err)
 	var buf bytes.Buffer
 	err = gen.Do("", &buf)
@@ -128,6 +183,278 @@ func DoSomething(fn func(a, b NUMBER) bool, a, b NUMBER) NUMBER {
 	assert.Equal(t, 1, strings.Count(out, "func DoSomethingInt64(fn func(a, b int64) bool, a, b int64) int64 {\n"), out)
 }
 
+func TestFreeFunctions(t *testing.T) {
+	out := gen(t, `package test
+
+//generic
+type T int
+
+//generic
+type ITEM int
+
+// Max returns the larger value
+func Max(a, b T) T {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// MaxOf returns the largest value
+func MaxOf(v ...T) T {
+	m := v[0]
+	reduce := func(f func(a, b T) T) {
+		for _, x := range v {
+			m = f(m, x)
+		}
+	}
+	reduce(Max)
+	return m
+}
+
+// Filter returns the items accepted by f
+func Filter(s []ITEM, f func(ITEM) bool) []ITEM {
+	var r []ITEM
+	for _, i := range s {
+		if f(i) {
+			r = append(r, i)
+		}
+	}
+	return r
+}
+
+var maxFunc = Max
+
+type Opts struct {
+	Max int
+}
+
+var defaults = Opts{Max: 3}
+
+type Box struct {
+	v ITEM
+}
+
+// Max returns the other box
+func (b Box) Max(o Box) Box {
+	return o
+}
+
+func use() T {
+	get := func() T { return maxFunc(1, 2) }
+	return get()
+}`, "int64,string")
+
+	assert.Contains(t, out, "func MaxInt64(a, b int64) int64 {\n")
+	assert.Contains(t, out, "\treduce(MaxInt64)\n")
+	assert.Contains(t, out, "func FilterString(s []string, f func(string) bool) []string {\n")
+	assert.Contains(t, out, "var maxFuncInt64 = MaxInt64\n")
+	assert.Contains(t, out, "return maxFuncInt64(1, 2)")
+	// the key of the composite literal and the method are not renamed
+	assert.Contains(t, out, "var defaults = Opts{Max: 3}\n")
+	assert.Contains(t, out, "// Max returns the other box\nfunc (b BoxString) Max(o BoxString) BoxString {\n")
+	assert.Equal(t, 1, strings.Count(out, "type Opts struct"), out)
+}
+
+func TestResolveByObject(t *testing.T) {
+	out := gen(t, `package test
+
+//generic
+type T int
+
+type List struct {
+	items []T
+}
+
+type Wrapper struct {
+	List
+	n int
+}
+
+func (w *Wrapper) Len() int {
+	return len(w.List.items)
+}
+
+func (w *Wrapper) Sum() T {
+	var sum T
+	for List := range w.items {
+		sum += w.items[List]
+	}
+	return sum
+}
+
+func count(l List) int {
+	return len(l.items)
+}
+
+func use(w *Wrapper) func() int {
+	count := count
+	_ = count
+	return w.Len
+}`, "int64")
+
+	assert.Contains(t, out, "type WrapperInt64 struct {\n\tListInt64\n")
+	assert.Contains(t, out, "return len(w.ListInt64.items)")
+	// the shadowing variable is not renamed
+	assert.Contains(t, out, "for List := range w.items {\n\t\tsum += w.items[List]\n")
+	assert.Contains(t, out, "\tcount := countInt64\n\t_ = count\n\treturn w.Len\n")
+}
+
+func TestImportedValues(t *testing.T) {
+	out := gen(t, `package test
+
+import "example.com/store"
+
+//generic
+type ITEM int
+
+type List struct {
+	delegate store.List
+}
+
+func (l *List) Get(i int) ITEM {
+	item, ok := l.delegate.Get(i).(ITEM)
+	if !ok {
+		panic(store.Error{List: l.delegate})
+	}
+	return item
+}`, "int64")
+
+	assert.Contains(t, out, "item, ok := l.delegate.Get(i).(int64)\n")
+	assert.Contains(t, out, "type ListInt64 struct {\n\tdelegate store.List\n")
+	assert.Contains(t, out, "panic(store.Error{List: l.delegate})")
+}
+
+const boxFile = `package test
+
+type Box struct {
+	n int
+}
+`
+
+const boxMethodFile = `package test
+
+//generic
+type T int
+
+func (b *Box) Get() T {
+	var t T
+	return t
+}
+`
+
+func TestReceiverInOtherFile(t *testing.T) {
+	fset, files := parseFiles(t, boxFile, boxMethodFile)
+	c, err := concrete.New("int32;string")
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	err = NewPackage(fset, files, c).Do("", &buf)
+	assert.NoError(t, err)
+	out := buf.String()
+
+	assert.Equal(t, 1, strings.Count(out, "type BoxInt32 struct {\n"), out)
+	assert.Equal(t, 1, strings.Count(out, "func (b *BoxString) Get() string {\n"), out)
+	assert.Equal(t, 0, strings.Count(out, "type Box struct"), out)
+}
+
+func TestReceivers(t *testing.T) {
+	out := gen(t, `package test
+
+//generic
+type T int
+
+type List struct {
+	items []T
+}
+
+func (List) Name() string {
+	return "list"
+}
+
+func (l (*List)) Len() int {
+	return len(l.items)
+}`, "int32;string")
+
+	assert.Contains(t, out, "func (ListInt32) Name() string {\n")
+	assert.Contains(t, out, "func (l *ListString) Len() int {\n")
+	assert.Equal(t, 0, strings.Count(out, "List)"), out)
+}
+
+func TestReceiverErrors(t *testing.T) {
+	err := genErr(`package test
+
+//generic
+type T int
+
+func (t T) Double() T {
+	return t * 2
+}`, "int32")
+	if assert.Error(t, err) {
+		assert.Equal(t, "max.go:6:9: the method Double can not be declared on the generic type T", err.Error())
+	}
+
+	err = genErr(`package test
+
+//generic
+type T int
+
+func (o *Other) Get() (t T) {
+	return
+}`, "int32;int64")
+	if assert.Error(t, err) {
+		assert.Equal(t, "max.go:6:6: the method Get depends on a generic type but its receiver type Other is not declared in the template", err.Error())
+	}
+}
+
+func TestConstantsAndVariables(t *testing.T) {
+	out := gen(t, `package test
+
+//generic
+type ITEM int
+
+// Zero is the zero item
+const Zero ITEM = 0
+
+var defaultCap = 16
+
+const (
+	// First is the first item
+	First ITEM = iota
+	Second
+	Third
+)
+
+type Kind int
+
+const (
+	Small Kind = iota
+	Large
+)
+
+var empty = Zero
+
+type List struct {
+	items []ITEM
+	kind  Kind
+}
+
+func New() *List {
+	return &List{items: make([]ITEM, 0, defaultCap)}
+}
+
+func (l *List) IsZero(i int) bool {
+	return l.items[i] == empty || l.items[i] == Third
+}`, "int32;int64")
+
+	assert.Equal(t, 1, strings.Count(out, "var defaultCap = 16\n"), out)
+	assert.Equal(t, 1, strings.Count(out, "const (\n\tSmall\tKind\t= iota\n\tLarge\n)\n"), out)
+	assert.Equal(t, 1, strings.Count(out, "// ZeroInt32 is the zero item\nconst ZeroInt32 int32 = 0\n"), out)
+	assert.Equal(t, 1, strings.Count(out, "const (\n\t// FirstInt64 is the first item\n\tFirstInt64\tint64\t= iota\n\tSecondInt64\n\tThirdInt64\n)\n"), out)
+	assert.Equal(t, 1, strings.Count(out, "var emptyInt64 = ZeroInt64\n"), out)
+	assert.Equal(t, 1, strings.Count(out, "return l.items[i] == emptyInt32 || l.items[i] == ThirdInt32\n"), out)
+}
+
 func TestFunctionExpand(t *testing.T) {
 	out := gen(t, `package test
 
@@ -211,3 +538,690 @@ func (l Wrapper) Add(item ITEM) {
 	assert.Equal(t, 1, strings.Count(out, "type WrapperFloat64 struct"), out)
 	assert.Equal(t, 1, strings.Count(out, "func (l WrapperFloat64) Add(item float64)"), out)
 }
+
+func TestComments(t *testing.T) {
+	out := gen(t, `package test
+
+//generic
+type ITEM int
+
+// List stores the ITEM values
+type List struct {
+	items []ITEM // the items in the List
+}
+
+// Len returns the number of elements in the List
+func (l *List) Len() int {
+	// no need to lock the List
+	return len(l.items)
+}
+
+// Version is the same for all lists
+const Version = 1
+`, "int32;int64")
+
+	assert.Equal(t, 1, strings.Count(out, "// ListInt32 stores the int32 values\ntype ListInt32 struct {\n"), out)
+	assert.Equal(t, 1, strings.Count(out, "// the items in the ListInt32\n"), out)
+	assert.Equal(t, 1, strings.Count(out, "// Len returns the number of elements in the ListInt64\nfunc (l *ListInt64) Len() int {\n"), out)
+	assert.Equal(t, 1, strings.Count(out, "// no need to lock the ListInt64\n"), out)
+	assert.Equal(t, 1, strings.Count(out, "// Version is the same for all lists\nconst Version = 1\n"), out)
+	assert.Equal(t, 0, strings.Count(out, "generic"), out)
+}
+
+func TestCommentsInGroupedDecls(t *testing.T) {
+	out := gen(t, `package test
+
+//generic
+type ITEM int
+
+var (
+	// a is the first value
+	a ITEM
+	// b is the second value
+	b int
+)
+`, "int32")
+
+	assert.Equal(t, 1, strings.Count(out, "// aInt32 is the first value\nvar aInt32 int32\n"), out)
+	assert.Equal(t, 1, strings.Count(out, "// b is the second value\nvar b int\n"), out)
+}
+
+func TestTypeExpressions(t *testing.T) {
+	out := gen(t, `package test
+
+//generic
+type ITEM int
+
+type List struct {
+	items []ITEM
+}
+
+func New() *List {
+	return &List{}
+}
+`, "map[string]int;func(a, b int) bool")
+
+	assert.Equal(t, 1, strings.Count(out, "type ListMapStringInt struct {\n\titems []map[string]int\n}"), out)
+	assert.Equal(t, 1, strings.Count(out, "func NewMapStringInt() *ListMapStringInt {\n"), out)
+	assert.Equal(t, 1, strings.Count(out, "type ListFuncIntIntBool struct {\n\titems []func(a, b int) bool\n}"), out)
+	assert.Equal(t, 1, strings.Count(out, "func NewFuncIntIntBool() *ListFuncIntIntBool {\n"), out)
+}
+
+func TestNamedInstances(t *testing.T) {
+	out := gen(t, `package test
+
+//generic
+type KEY int
+//generic
+type VALUE int
+
+type KeyMagic struct {
+	lastKey KEY
+}
+
+type Map struct {
+	items    map[KEY]VALUE
+	keyMagic KeyMagic
+}
+
+func New() *Map {
+	return &Map{make(map[KEY]VALUE), KeyMagic{}}
+}`, "Users=string,*models.User;Groups=string,*models.Group;int,int")
+
+	assert.Equal(t, 1, strings.Count(out, "type KeyMagicString struct"), out)
+	assert.Equal(t, 1, strings.Count(out, "type MapUsers struct"), out)
+	assert.Equal(t, 1, strings.Count(out, "func NewUsers() *MapUsers {"), out)
+	assert.Equal(t, 1, strings.Count(out, "type MapGroups struct"), out)
+	assert.Equal(t, 1, strings.Count(out, "func NewGroups() *MapGroups {"), out)
+	assert.Equal(t, 1, strings.Count(out, "type KeyMagicInt struct"), out)
+	assert.Equal(t, 1, strings.Count(out, "type MapIntInt struct"), out)
+	assert.Equal(t, 2, strings.Count(out, "KeyMagicString{}"), out)
+}
+
+func genInPackage(t *testing.T, code string, types string, packagePath string) string {
+	fset, file := parseFile(t, code)
+
+	c, err := concrete.New(types)
+	assert.NoError(t, err)
+
+	gen := New(fset, file, c)
+	gen.PackagePath = packagePath
+	var buf bytes.Buffer
+	err = gen.Do("", &buf)
+	assert.NoError(t, err)
+
+	return buf.String()
+}
+
+const qualifiedTemplate = `package test
+
+import "github.com/acme/lib/models"
+
+//generic
+type ITEM int
+
+var defaultGroup models.Group
+
+type List struct {
+	items []ITEM
+}
+`
+
+func TestQualifiedTypeImport(t *testing.T) {
+	out := genInPackage(t, qualifiedTemplate, "*github.com/acme/app/models.User;gopkg.in/yaml.v3.Node", "github.com/acme/app")
+
+	assert.Equal(t, 1, strings.Count(out, "\"github.com/acme/lib/models\"\n"), out)
+	assert.Equal(t, 1, strings.Count(out, "models2 \"github.com/acme/app/models\"\n"), out)
+	assert.Equal(t, 1, strings.Count(out, "yaml \"gopkg.in/yaml.v3\"\n"), out)
+	assert.Equal(t, 1, strings.Count(out, "type ListPmodels2User struct {\n\titems []*models2.User\n}"), out)
+	assert.Equal(t, 1, strings.Count(out, "type ListYamlNode struct {\n\titems []yaml.Node\n}"), out)
+}
+
+func TestQualifiedTypeSamePackage(t *testing.T) {
+	out := genInPackage(t, qualifiedTemplate, "*github.com/acme/app/models.User", "github.com/acme/app/models")
+
+	assert.Equal(t, 1, strings.Count(out, "\"github.com/acme/lib/models\"\n"), out)
+	assert.Equal(t, 0, strings.Count(out, "\"github.com/acme/app/models\""), out)
+	assert.Equal(t, 1, strings.Count(out, "type ListPUser struct {\n\titems []*User\n}"), out)
+}
+
+func TestQualifiedTypeImportedByTemplate(t *testing.T) {
+	out := genInPackage(t, qualifiedTemplate, "github.com/acme/lib/models.User", "github.com/acme/app")
+
+	assert.Equal(t, 1, strings.Count(out, "\"github.com/acme/lib/models\""), out)
+	assert.Equal(t, 1, strings.Count(out, "type ListModelsUser struct {\n\titems []models.User\n}"), out)
+}
+
+func parseFiles(t *testing.T, codes ...string) (*token.FileSet, []*ast.File) {
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for i, code := range codes {
+		file, err := parser.ParseFile(fset, fmt.Sprintf("file%d.go", i), code, parser.ParseComments)
+		assert.NoError(t, err)
+		files = append(files, file)
+	}
+	return fset, files
+}
+
+const listFile = `package test
+
+import "fmt"
+
+//generic
+type ITEM int
+
+// List stores the items
+type List struct {
+	items []ITEM
+}
+
+func (l *List) String() string {
+	return fmt.Sprint(l.items)
+}
+`
+
+const iterFile = `package test
+
+import "fmt"
+
+// Iter iterates over a List
+type Iter struct {
+	list *List
+	pos  int
+}
+
+// Iter creates a new Iter
+func (l *List) Iter() *Iter {
+	return &Iter{list: l}
+}
+
+func (i *Iter) Print() {
+	fmt.Print(i.list)
+}
+
+func version() string {
+	return "1"
+}
+`
+
+func TestMultiFile(t *testing.T) {
+	fset, files := parseFiles(t, listFile, iterFile)
+	c, err := concrete.New("int32;string")
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	err = NewPackage(fset, files, c).Do("", &buf)
+	assert.NoError(t, err)
+	out := buf.String()
+
+	assert.Equal(t, 1, strings.Count(out, "package test\n"), out)
+	assert.Equal(t, 1, strings.Count(out, "import \"fmt\"\n"), out)
+	assert.Equal(t, 1, strings.Count(out, "func version() string {"), out)
+	assert.Equal(t, 1, strings.Count(out, "type IterInt32 struct {\n\tlist\t*ListInt32\n"), out)
+	assert.Equal(t, 1, strings.Count(out, "func (l *ListInt32) Iter() *IterInt32 {\n\treturn &IterInt32{list: l}\n}"), out)
+	assert.Equal(t, 1, strings.Count(out, "func (i *IterString) Print() {"), out)
+	assert.Equal(t, 1, strings.Count(out, "// IterString iterates over a ListString\n"), out)
+}
+
+func TestDoPerFile(t *testing.T) {
+	fset, files := parseFiles(t, listFile, iterFile)
+	c, err := concrete.New("int32;github.com/acme/app/models.User")
+	assert.NoError(t, err)
+
+	outputs := map[string]*bytes.Buffer{}
+	err = NewPackage(fset, files, c).DoPerFile("out", func(templateFile string) (io.Writer, error) {
+		outputs[templateFile] = new(bytes.Buffer)
+		return outputs[templateFile], nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(outputs))
+
+	list := outputs["file0.go"].String()
+	assert.Equal(t, 1, strings.Count(list, "package out\n"), list)
+	assert.Equal(t, 1, strings.Count(list, "\"github.com/acme/app/models\"\n"), list)
+	assert.Equal(t, 1, strings.Count(list, "type ListModelsUser struct {"), list)
+	assert.Equal(t, 0, strings.Count(list, "Iter"), list)
+
+	iter := outputs["file1.go"].String()
+	assert.Equal(t, 1, strings.Count(iter, "package out\n"), iter)
+	assert.Equal(t, 1, strings.Count(iter, "\"github.com/acme/app/models\"\n"), iter)
+	assert.Equal(t, 1, strings.Count(iter, "type IterModelsUser struct {"), iter)
+	assert.Equal(t, 1, strings.Count(iter, "func (l *ListInt32) Iter() *IterInt32 {"), iter)
+	assert.Equal(t, 0, strings.Count(iter, "type List"), iter)
+}
+
+func TestDeclarationOrder(t *testing.T) {
+	out := gen(t, `package test
+
+func (i *Iter) Next() {
+	i.pos++
+}
+
+type Iter struct {
+	list *List
+	pos  int
+}
+
+//generic
+type ITEM int
+
+type List struct {
+	items []ITEM
+}
+`, "int32")
+
+	assert.Equal(t, 1, strings.Count(out, "func (i *IterInt32) Next() {"), out)
+	assert.Equal(t, 0, strings.Count(out, "func (i *Iter) Next() {"), out)
+}
+
+func TestDoTests(t *testing.T) {
+	fset := token.NewFileSet()
+	list, err := parser.ParseFile(fset, "list.go", listFile, parser.ParseComments)
+	assert.NoError(t, err)
+	test, err := parser.ParseFile(fset, "list_test.go", `package test
+
+import "testing"
+
+//hook
+func value(i int) ITEM {
+	return ITEM(i)
+}
+
+func TestString(t *testing.T) {
+	l := List{items: []ITEM{value(1)}}
+	if l.String() != "[1]" {
+		t.Fail()
+	}
+}
+
+func TestStatic(t *testing.T) {
+}
+
+func Example() {
+	l := List{}
+	fmt.Println(l.String())
+}
+
+func ExampleList_String() {
+	l := List{}
+	fmt.Println(l.String())
+}
+`, parser.ParseComments)
+	assert.NoError(t, err)
+
+	c, err := concrete.New("int32;string")
+	assert.NoError(t, err)
+	g := NewPackage(fset, []*ast.File{list, test}, c)
+	assert.True(t, g.HasTests())
+
+	var buf bytes.Buffer
+	assert.NoError(t, g.Do("", &buf))
+	out := buf.String()
+	assert.Equal(t, 1, strings.Count(out, "type ListInt32 struct"), out)
+	assert.Equal(t, 0, strings.Count(out, "Test"), out)
+
+	buf.Reset()
+	assert.NoError(t, g.DoTests("", &buf))
+	out = buf.String()
+	assert.Equal(t, 1, strings.Count(out, "import \"testing\"\n"), out)
+	assert.Equal(t, 0, strings.Count(out, "ListInt32 struct"), out)
+	assert.Equal(t, 0, strings.Count(out, "func value"), out)
+	assert.Equal(t, 1, strings.Count(out, "func TestStatic(t *testing.T) {"), out)
+	assert.Equal(t, 1, strings.Count(out, "func TestStringInt32(t *testing.T) {\n\tl := ListInt32{items: []int32{valueInt32(1)}}"), out)
+	assert.Equal(t, 1, strings.Count(out, "func TestStringString(t *testing.T) {\n\tl := ListString{items: []string{valueString(1)}}"), out)
+	assert.Equal(t, 1, strings.Count(out, "func Example_int32() {"), out)
+	assert.Equal(t, 1, strings.Count(out, "func Example_string() {"), out)
+	assert.Equal(t, 1, strings.Count(out, "func ExampleListInt32_String() {"), out)
+	assert.Equal(t, 1, strings.Count(out, "func ExampleListString_String() {"), out)
+}
+
+func genErr(code string, types string) error {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "max.go", code, parser.ParseComments)
+	if err != nil {
+		return err
+	}
+	c, err := concrete.New(types)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	return New(fset, file, c).Do("", &buf)
+}
+
+const maxFile = `package test
+
+//generic ordered
+type ITEM int
+
+func Max(a, b ITEM) ITEM {
+	if a < b {
+		return b
+	}
+	return a
+}
+`
+
+func TestConstraints(t *testing.T) {
+	tests := []struct {
+		constraint string
+		types      string
+		err        string
+	}{
+		{constraint: "ordered", types: "int;string;float64"},
+		{constraint: "ordered", types: "int;struct{}", err: "max.go:3:1: instance 2 uses struct{} for the generic type ITEM, which does not satisfy the constraint ordered"},
+		{constraint: "ordered", types: "complex128", err: "max.go:3:1: instance 1 uses complex128"},
+		{constraint: "numeric", types: "int;complex128;uint8"},
+		{constraint: "numeric", types: "string", err: "does not satisfy the constraint numeric"},
+		{constraint: "comparable", types: "string;[2]int;struct{a int};*int"},
+		{constraint: "comparable", types: "[]int", err: "does not satisfy the constraint comparable"},
+		{constraint: "comparable", types: "map[int]int", err: "does not satisfy the constraint comparable"},
+		{constraint: "comparable numeric", types: "int"},
+		{constraint: "implements error", types: "error"},
+		{constraint: "implements error", types: "int", err: "does not satisfy the constraint implements error"},
+		{constraint: "implements int", types: "int", err: "max.go:3:1: int is not an interface"},
+		// a comment which contains other words is not a marker
+		{constraint: "implements", types: "int", err: "no generic types found"},
+		{constraint: "sortable", types: "int", err: "no generic types found"},
+		{constraint: "ordered", types: "unknown", err: "checking the constraints: can not resolve type unknown"},
+	}
+	for _, test := range tests {
+		t.Run(test.constraint+" "+test.types, func(t *testing.T) {
+			err := genErr(strings.Replace(maxFile, "ordered", test.constraint, 1), test.types)
+			if test.err == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), test.err)
+			}
+		})
+	}
+}
+
+func TestGenericComment(t *testing.T) {
+	out := gen(t, `package test
+
+//generic
+type ITEM int
+
+// generic type used for the list items
+type Item struct {
+	value ITEM
+}
+
+// generic list
+type List struct {
+	items []Item
+}`, "int32")
+
+	assert.Contains(t, out, "// generic type used for the list items\ntype ItemInt32 struct {\n\tvalue int32\n}")
+	assert.Contains(t, out, "// generic list\ntype ListInt32 struct {\n\titems []ItemInt32\n}")
+}
+
+func TestConstraintsRepeated(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "max.go", maxFile, parser.ParseComments)
+	assert.NoError(t, err)
+	c, err := concrete.New("struct{}")
+	assert.NoError(t, err)
+
+	// the error is returned by every call, nothing is written
+	g := New(fset, file, c)
+	var buf bytes.Buffer
+	assert.Error(t, g.Do("", &buf))
+	assert.Error(t, g.Do("", &buf))
+	assert.Error(t, g.Explain(&buf))
+	assert.Equal(t, 0, strings.Count(buf.String(), "func Max"), buf.String())
+}
+
+func TestOrigin(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "max.go", maxFile+`
+func helper() int {
+	return 1
+}
+`, parser.ParseComments)
+	assert.NoError(t, err)
+	c, err := concrete.New("int;string")
+	assert.NoError(t, err)
+
+	g := New(fset, file, c)
+	var buf bytes.Buffer
+	assert.NoError(t, g.Do("", &buf))
+	source := buf.Bytes()
+
+	line := func(text string) token.Position {
+		p := bytes.Index(source, []byte(text))
+		assert.True(t, p >= 0, text)
+		return token.Position{Line: bytes.Count(source[:p], []byte("\n")) + 1, Column: 2}
+	}
+
+	pos, instance, ok := g.Origin(&buf, source, line("return 1"))
+	assert.True(t, ok)
+	assert.Equal(t, -1, instance)
+	assert.Equal(t, "max.go:14:2", pos.String())
+
+	pos, instance, ok = g.Origin(&buf, source, line("a < b"))
+	assert.True(t, ok)
+	assert.Equal(t, 0, instance)
+	assert.Equal(t, "max.go:7:2", pos.String())
+
+	pos, instance, ok = g.Origin(&buf, source, line("func MaxString"))
+	assert.True(t, ok)
+	assert.Equal(t, 1, instance)
+	assert.Equal(t, "max.go:6:2", pos.String())
+
+	_, _, ok = g.Origin(&buf, source, token.Position{Line: 1, Column: 1})
+	assert.False(t, ok)
+}
+
+func TestLineDirectives(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "temp/max.go", maxFile+`
+// helper is static
+func helper() int {
+	return 1
+}
+`, parser.ParseComments)
+	assert.NoError(t, err)
+	c, err := concrete.New("int;string")
+	assert.NoError(t, err)
+
+	g := New(fset, file, c)
+	g.LineDirectives = true
+	var buf bytes.Buffer
+	assert.NoError(t, g.Do("", &buf))
+	assert.Contains(t, buf.String(), "//line temp/max.go:5\n\nfunc MaxInt(")
+
+	outFset := token.NewFileSet()
+	out, err := parser.ParseFile(outFset, "", buf.Bytes(), parser.ParseComments)
+	assert.NoError(t, err)
+	var positions []string
+	for _, d := range out.Decls {
+		positions = append(positions, outFset.Position(d.Pos()).String())
+	}
+	assert.Equal(t, []string{"temp/max.go:14", "temp/max.go:6", "temp/max.go:6"}, positions)
+}
+
+func TestTemplateReuse(t *testing.T) {
+	fset, files := parseFiles(t, listFile, iterFile)
+
+	generate := func(types string) string {
+		c, err := concrete.New(types)
+		assert.NoError(t, err)
+		var buf bytes.Buffer
+		assert.NoError(t, NewPackage(fset, files, c).Do("", &buf))
+		return buf.String()
+	}
+
+	first := generate("int")
+	second := generate("string")
+	assert.Contains(t, second, "ListString")
+	assert.NotContains(t, second, "ListInt")
+	assert.Equal(t, first, generate("int"))
+}
+
+func TestExplain(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "list.go", `package test
+
+//generic
+type ITEM int
+
+type List struct {
+	items []ITEM
+}
+
+type Wrapper struct {
+	list List
+}
+
+type Counter struct {
+	n int
+}
+
+func (c *Counter) Add(i ITEM) {
+	c.n++
+}
+
+const Version = 1
+`, parser.ParseComments)
+	assert.NoError(t, err)
+	c, err := concrete.New("int;string")
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	assert.NoError(t, New(fset, file, c).Explain(&buf))
+	assert.Equal(t, `generic type ITEM
+instance 1: ITEM=int
+instance 2: ITEM=string
+
+list.go:6:1: type List
+	depends on ITEM: uses ITEM at list.go:7:10
+	written per instance: ListInt, ListString
+
+list.go:10:1: type Wrapper
+	depends on ITEM: refers to type List at list.go:11:7, which uses ITEM at list.go:7:10
+	written per instance: WrapperInt, WrapperString
+
+list.go:14:1: type Counter
+	depends on ITEM: has the method func (Counter) Add at list.go:18:1, which uses ITEM at list.go:18:25
+	written per instance: CounterInt, CounterString
+
+list.go:18:1: func (Counter) Add
+	depends on ITEM: uses ITEM at list.go:18:25
+	written per instance: (CounterInt) Add, (CounterString) Add
+
+list.go:22:1: const Version
+	depends on no generic type, written once
+`, buf.String())
+}
+
+const zeroFile = `package test
+
+//generic
+type ITEM int
+
+//zero
+func zero() ITEM {
+	var z ITEM
+	return z
+}
+
+// Get returns the item or the zero value
+func Get(items []ITEM, i int) ITEM {
+	if i < len(items) {
+		return items[i]
+	}
+	return zero()
+}
+`
+
+func TestZero(t *testing.T) {
+	fset, file := parseFile(t, zeroFile)
+	c, err := concrete.New("int32;string;bool;*int;[]byte;error;func();struct{a int};[2]int;Foo")
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	assert.NoError(t, New(fset, file, c).Do("", &buf))
+	out := buf.String()
+
+	assert.Equal(t, 0, strings.Count(out, "zero()"), out)
+	assert.Contains(t, out, "func GetInt32(items []int32, i int) int32 {")
+	assert.Contains(t, out, "\treturn int32(0)\n")
+	assert.Contains(t, out, "\treturn string(\"\")\n")
+	assert.Contains(t, out, "\treturn bool(false)\n")
+	assert.Contains(t, out, "\treturn (*int)(nil)\n")
+	assert.Contains(t, out, "\treturn []byte(nil)\n")
+	assert.Contains(t, out, "\treturn error(nil)\n")
+	assert.Contains(t, out, "\treturn (func())(nil)\n")
+	assert.Contains(t, out, "\treturn (struct{a int}{})\n")
+	assert.Contains(t, out, "\treturn ([2]int{})\n")
+	// the underlying type of Foo is not known
+	assert.Contains(t, out, "\treturn *new(Foo)\n")
+
+	// the template is restored
+	buf.Reset()
+	assert.NoError(t, New(fset, file, c).Do("", &buf))
+	assert.Equal(t, out, buf.String())
+	assert.Contains(t, getSource(t, file), "\treturn zero()\n")
+}
+
+const zeroUseFile = `package test
+
+//generic
+type ITEM int
+
+//zero
+func zero() ITEM {
+	var z ITEM
+	return z
+}
+
+func Use(x ITEM) ITEM {
+	z := zero()
+	var v = zero()
+	if x == zero() {
+		return z
+	}
+	for y := zero(); y != x; y = x {
+		v = y
+	}
+	switch zero() {
+	case x:
+		return v
+	}
+	return zero()
+}
+`
+
+// TestZeroTypes checks that the zero values have the concrete types and
+// can be used in every place the call of the zero function can be used.
+func TestZeroTypes(t *testing.T) {
+	fset, file := parseFile(t, zeroUseFile)
+	c, err := concrete.New("float64;int8;string;bool;*int;error;chan int;interface{};struct{a int};[2]int;P")
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	assert.NoError(t, New(fset, file, c).Do("", &buf))
+	src := buf.String() + "\ntype P struct{ a, b int }\n"
+
+	fset = token.NewFileSet()
+	f, err := parser.ParseFile(fset, "out.go", src, 0)
+	if assert.NoError(t, err, src) {
+		conf := types.Config{}
+		_, err = conf.Check("test", fset, []*ast.File{f}, nil)
+		assert.NoError(t, err, src)
+	}
+}
+
+func TestZeroErrors(t *testing.T) {
+	err := genErr(strings.Replace(zeroFile, "func zero() ITEM {", "func zero(i int) ITEM {", 1), "int32")
+	if assert.Error(t, err) {
+		assert.Equal(t, "max.go:7:1: the zero function zero has to return a generic type and must not have parameters", err.Error())
+	}
+
+	err = genErr(zeroFile+"\nvar zeroFunc = zero\n", "int32")
+	if assert.Error(t, err) {
+		assert.Equal(t, "max.go:20:16: the zero function zero can only be called without arguments", err.Error())
+	}
+}
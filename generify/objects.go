@@ -0,0 +1,135 @@
+package generify
+
+import (
+	"go/ast"
+	"go/types"
+
+	"github.com/hneemann/yagi/concrete"
+)
+
+// typeCheckTemplate type checks the template, so every identifier is resolved by its
+// types.Object. In contrast to the deprecated ast.Object, this resolution follows the
+// language rules: shadowed names, the keys of composite literals, selectors of embedded
+// fields and identifiers declared in an other file of the template are all resolved
+// correctly. The errors are ignored because the generic types are often used in a way
+// only valid for the concrete types; the information is used as far as it is available.
+func (g *Generify) typeCheckTemplate() {
+	g.info = &types.Info{Defs: map[*ast.Ident]types.Object{}, Uses: map[*ast.Ident]types.Object{}}
+	conf := types.Config{
+		Importer: emptyImporter{},
+		Error:    func(error) {},
+	}
+	g.pkg, _ = conf.Check(g.files[0].Name.Name, g.fset, g.files, g.info)
+
+	// The selectors and the keys of composite literals refer to fields or methods.
+	// They must not be resolved by their names if their objects are unknown.
+	g.members = map[*ast.Ident]bool{}
+	for _, f := range g.files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.SelectorExpr:
+				g.members[n.Sel] = true
+			case *ast.CompositeLit:
+				for _, e := range n.Elts {
+					if kv, ok := e.(*ast.KeyValueExpr); ok {
+						if id, ok := kv.Key.(*ast.Ident); ok {
+							g.members[id] = true
+						}
+					}
+				}
+			}
+			return true
+		})
+	}
+}
+
+// emptyImporter imports every package as an empty package. Only the declarations
+// of the template are to be resolved, so the imported packages are not needed,
+// and importing them from source would take much longer than the generation.
+type emptyImporter struct{}
+
+func (emptyImporter) Import(path string) (*types.Package, error) {
+	p := types.NewPackage(path, concrete.PackageName(path))
+	p.MarkComplete()
+	return p, nil
+}
+
+// objectOf returns the object declared by or referred to by the identifier, nil if it is unknown.
+// The type checker does not record the objects of identifiers in expressions it can not check,
+// e.g. in a type assertion of a value from an imported package, because the imported packages
+// are empty. Such an identifier is resolved by looking up its name in the enclosing scopes.
+func (g *Generify) objectOf(id *ast.Ident) types.Object {
+	if obj := g.info.Uses[id]; obj != nil {
+		return obj
+	}
+	if obj := g.info.Defs[id]; obj != nil {
+		return obj
+	}
+	if g.pkg == nil || g.members[id] {
+		return nil
+	}
+	scope := g.pkg.Scope().Innermost(id.Pos())
+	if scope == nil {
+		return nil
+	}
+	_, obj := scope.LookupParent(id.Name, id.Pos())
+	return obj
+}
+
+// typeNameOf returns the type name of a named type or of a pointer to a named type, nil otherwise
+func typeNameOf(t types.Type) *types.TypeName {
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	if named, ok := t.(*types.Named); ok {
+		return named.Obj()
+	}
+	return nil
+}
+
+// typeDeclOf returns the declaration of the given type name, nil if it is not declared in the template
+func (g *Generify) typeDeclOf(obj *types.TypeName) *declWithDependency {
+	if obj == nil {
+		return nil
+	}
+	for _, decl := range g.genericDecls {
+		if genDecl, ok := decl.decl.(*ast.GenDecl); ok && len(genDecl.Specs) > 0 {
+			if typeSpec, ok := genDecl.Specs[0].(*ast.TypeSpec); ok && g.objectOf(typeSpec.Name) == obj {
+				return decl
+			}
+		}
+	}
+	return nil
+}
+
+// receiverDecl returns the declaration of the receiver type of a method, nil if it is not declared in the template
+func (g *Generify) receiverDecl(f *ast.FuncDecl) *declWithDependency {
+	fn, ok := g.objectOf(f.Name).(*types.Func)
+	if !ok {
+		return nil
+	}
+	recv := fn.Type().(*types.Signature).Recv()
+	if recv == nil {
+		return nil
+	}
+	return g.typeDeclOf(typeNameOf(recv.Type()))
+}
+
+// receiverType returns the type expression of the receiver of a method
+// without parentheses and pointer, nil if the method has no receiver.
+func receiverType(f *ast.FuncDecl) ast.Expr {
+	if f.Recv == nil || len(f.Recv.List) == 0 {
+		return nil
+	}
+	exp := f.Recv.List[0].Type
+	for {
+		switch e := exp.(type) {
+		case *ast.ParenExpr:
+			exp = e.X
+		case *ast.StarExpr:
+			exp = e.X
+		default:
+			return exp
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package generify
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/types"
+	"strings"
+
+	"github.com/hneemann/yagi/concrete"
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// isZero checks if the declaration is a function marked with the "zero" comment.
+// A zero function returns the zero value of a generic type, e.g.
+//
+//	//zero
+//	func zero() ITEM {
+//		var z ITEM
+//		return z
+//	}
+//
+// The function is never written, instead every call is replaced by the zero value
+// of the concrete type like int32(0), string(""), (*T)(nil) or (T{}).
+func isZero(decl ast.Decl) bool {
+	funcDecl, ok := decl.(*ast.FuncDecl)
+	return ok && strings.TrimSpace(funcDecl.Doc.Text()) == "zero"
+}
+
+// findZeroCalls checks the zero functions and creates the actions which
+// replace their calls by the zero values
+func (g *Generify) findZeroCalls() error {
+	g.zeroFuncs = map[types.Object]int{}
+	for _, decl := range g.genericDecls {
+		if decl.zero {
+			funcDecl := decl.decl.(*ast.FuncDecl)
+			index, ok := g.zeroType(funcDecl)
+			if !ok {
+				return g.errorf(funcDecl.Pos(), "the zero function %s has to return a generic type and must not have parameters", funcDecl.Name.Name)
+			}
+			g.zeroFuncs[g.objectOf(funcDecl.Name)] = index
+		}
+	}
+	if len(g.zeroFuncs) == 0 {
+		return nil
+	}
+
+	for _, decl := range g.genericDecls {
+		if decl.zero {
+			continue
+		}
+		var err error
+		zr := &zeroRename{g: g, decl: decl.decl, calls: map[*ast.CallExpr]int{}, values: map[*ast.Ident]*ast.CallExpr{}}
+		called := map[*ast.Ident]bool{}
+		ast.Inspect(decl.decl, func(n ast.Node) bool {
+			if err != nil {
+				return false
+			}
+			switch n := n.(type) {
+			case *ast.CallExpr:
+				if id, ok := n.Fun.(*ast.Ident); ok && len(n.Args) == 0 {
+					if index, ok := g.zeroFuncs[g.objectOf(id)]; ok {
+						called[id] = true
+						zr.calls[n] = index
+					}
+				}
+			case *ast.Ident:
+				if _, ok := g.zeroFuncs[g.objectOf(n)]; ok && !called[n] {
+					err = g.errorf(n.Pos(), "the zero function %s can only be called without arguments", n.Name)
+				}
+			}
+			return true
+		})
+		if err != nil {
+			return err
+		}
+		if len(zr.calls) > 0 {
+			g.addRenameAction(zr)
+		}
+	}
+	return nil
+}
+
+// zeroType returns the index of the generic type returned by the zero function
+func (g *Generify) zeroType(f *ast.FuncDecl) (int, bool) {
+	if f.Recv != nil || f.Type.Params.NumFields() > 0 || f.Type.Results.NumFields() != 1 {
+		return 0, false
+	}
+	if id, ok := f.Type.Results.List[0].Type.(*ast.Ident); ok {
+		obj := g.objectOf(id)
+		for i, gen := range g.genObjects {
+			if obj != nil && obj == gen {
+				return i, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// resolveZeroValues finds the zero values of the concrete types returned by the zero functions
+func (g *Generify) resolveZeroValues() {
+	if len(g.zeroFuncs) == 0 {
+		return
+	}
+
+	var exprs []string
+	g.zeroValues = map[string]string{}
+	for _, index := range g.zeroFuncs {
+		for _, ct := range g.concreteTypes.Instance {
+			if _, ok := g.zeroValues[ct[index]]; !ok {
+				g.zeroValues[ct[index]] = ""
+				exprs = append(exprs, ct[index])
+			}
+		}
+	}
+
+	resolver := g.Resolver
+	if resolver == nil {
+		resolver = universeResolver{}
+	}
+	resolved, err := resolver.Resolve(exprs, g.concreteImports)
+	for i, expr := range exprs {
+		var t types.Type
+		if err == nil {
+			t = resolved[i]
+		} else if r, err := (universeResolver{}).Resolve([]string{expr}, nil); err == nil {
+			t = r[0]
+		}
+		g.zeroValues[expr] = zeroValue(expr, t)
+	}
+}
+
+// zeroValue returns the zero value of the given type expression. The value is typed,
+// so it has the same meaning as the call of the zero function, e.g. int32(0), (*T)(nil)
+// or (T{}). If the type is not known, the zero value is created by new.
+func zeroValue(expr string, t types.Type) string {
+	if t == nil {
+		return "*new(" + expr + ")"
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case u.Info()&types.IsBoolean != 0:
+			return conversion(expr, "false")
+		case u.Info()&types.IsString != 0:
+			return conversion(expr, `""`)
+		case u.Info()&types.IsNumeric != 0:
+			return conversion(expr, "0")
+		}
+	case *types.Struct, *types.Array:
+		// the parentheses are needed in the header of an if, for or switch statement
+		return "(" + expr + "{})"
+	}
+	return conversion(expr, "nil")
+}
+
+// conversion returns the conversion of the value to the given type expression.
+// The type is put in parentheses if it would not be parsed as a type otherwise, like *T or func().
+func conversion(expr, value string) string {
+	e, err := parser.ParseExpr(expr)
+	if err == nil {
+		switch e.(type) {
+		case *ast.Ident, *ast.SelectorExpr, *ast.ArrayType, *ast.MapType, *ast.InterfaceType:
+			return expr + "(" + value + ")"
+		}
+	}
+	return "(" + expr + ")(" + value + ")"
+}
+
+// zeroRename replaces the calls of zero functions in a declaration by the zero values
+// of the concrete types. Each call is replaced by an identifier whose name is the zero
+// value, so the printer writes it as it is.
+type zeroRename struct {
+	g    *Generify
+	decl ast.Decl
+	// the calls and the indices of the generic types they return
+	calls map[*ast.CallExpr]int
+	// the identifiers which replace the calls
+	values map[*ast.Ident]*ast.CallExpr
+}
+
+func (zr *zeroRename) rename(ct concrete.Types, _ string) {
+	astutil.Apply(zr.decl, nil, func(c *astutil.Cursor) bool {
+		switch n := c.Node().(type) {
+		case *ast.CallExpr:
+			if index, ok := zr.calls[n]; ok {
+				id := &ast.Ident{NamePos: n.Pos(), Name: zr.g.zeroValues[ct[index]]}
+				zr.values[id] = n
+				c.Replace(id)
+			}
+		case *ast.Ident:
+			if call, ok := zr.values[n]; ok {
+				n.Name = zr.g.zeroValues[ct[zr.calls[call]]]
+			}
+		}
+		return true
+	})
+}
+
+func (zr *zeroRename) restore() {
+	astutil.Apply(zr.decl, nil, func(c *astutil.Cursor) bool {
+		if id, ok := c.Node().(*ast.Ident); ok {
+			if call, ok := zr.values[id]; ok {
+				c.Replace(call)
+			}
+		}
+		return true
+	})
+	zr.values = map[*ast.Ident]*ast.CallExpr{}
+}
@@ -0,0 +1,264 @@
+// Package infer infers the concrete types which are needed by a package. This is done
+// by matching the identifiers which are used but not declared against the names the
+// declarations of a template get if they are renamed for an instance. So the name
+// NewStringInt64 matches the function New which depends on the generic types KEY and
+// VALUE, and requires an instance with KEY=string and VALUE=int64.
+package infer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/hneemann/yagi/concrete"
+)
+
+// Template describes the names a template creates
+type Template struct {
+	// GenericTypes are the names of the generic types
+	GenericTypes []string
+	// Renamed are the names of the renamed declarations with
+	// the indices of the generic types they depend on
+	Renamed map[string][]int
+}
+
+// assignment assigns concrete types to some of the generic types
+type assignment map[int]string
+
+// match is a possible meaning of an undefined identifier
+type match struct {
+	name       string
+	origName   string
+	assignment assignment
+}
+
+// Instances returns the instances which are needed to declare the undefined identifiers. The
+// type names are the types which can be used as concrete types. The result has the format of
+// the -gen flag. Identifiers which do not match a renamed name of the template are ignored.
+func Instances(t Template, undefined []string, typeNames []string) (string, error) {
+	p := newSuffixParser(typeNames)
+
+	var full []assignment
+	var partial []match
+	for _, name := range undefined {
+		m, err := t.match(p, name)
+		if err != nil {
+			return "", err
+		}
+		if m == nil {
+			continue
+		}
+		if len(m.assignment) == len(t.GenericTypes) {
+			if !contains(full, m.assignment) {
+				full = append(full, m.assignment)
+			}
+		} else {
+			partial = append(partial, *m)
+		}
+	}
+
+	// every name depending only on some generic types has to be
+	// declared by an instance found by a name depending on all of them
+	for _, m := range partial {
+		if !covered(full, m.assignment) {
+			return "", fmt.Errorf("%s depends on %s only, the other generic types can not be inferred", m.name, t.typeList(m.origName))
+		}
+	}
+	if len(full) == 0 {
+		return "", fmt.Errorf("no undefined identifier matches a name of the template")
+	}
+
+	var instances []string
+	for _, a := range full {
+		var types []string
+		for i := range t.GenericTypes {
+			types = append(types, a[i])
+		}
+		instances = append(instances, strings.Join(types, ","))
+	}
+	return strings.Join(instances, ";"), nil
+}
+
+// match finds the meaning of an undefined identifier. If there are several, an error is returned.
+func (t Template) match(p *suffixParser, name string) (*match, error) {
+	var matches []match
+	for origName, indices := range t.Renamed {
+		if len(indices) == 0 || !strings.HasPrefix(name, origName) {
+			continue
+		}
+		for _, types := range p.parse(name[len(origName):], len(indices)) {
+			a := assignment{}
+			for j, i := range indices {
+				a[i] = types[j]
+			}
+			matches = append(matches, match{name: name, origName: origName, assignment: a})
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, nil
+	case 1:
+		return &matches[0], nil
+	}
+
+	var meanings []string
+	for _, m := range matches {
+		meanings = append(meanings, m.origName+"["+m.assignment.String()+"]")
+	}
+	sort.Strings(meanings)
+	return nil, fmt.Errorf("the meaning of %s is ambiguous: %s", name, strings.Join(meanings, " or "))
+}
+
+func (t Template) typeList(origName string) string {
+	var names []string
+	for _, i := range t.Renamed[origName] {
+		names = append(names, t.GenericTypes[i])
+	}
+	return strings.Join(names, ",")
+}
+
+func (a assignment) String() string {
+	var indices []int
+	for i := range a {
+		indices = append(indices, i)
+	}
+	sort.Ints(indices)
+	var types []string
+	for _, i := range indices {
+		types = append(types, a[i])
+	}
+	return strings.Join(types, ",")
+}
+
+// covers returns true if the full assignment contains the partial one
+func (a assignment) covers(partial assignment) bool {
+	for i, t := range partial {
+		if a[i] != t {
+			return false
+		}
+	}
+	return true
+}
+
+func covered(full []assignment, partial assignment) bool {
+	for _, a := range full {
+		if a.covers(partial) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []assignment, a assignment) bool {
+	for _, l := range list {
+		if len(l) == len(a) && l.covers(a) {
+			return true
+		}
+	}
+	return false
+}
+
+// suffixParser finds the types whose suffixes, as created by concrete.Suffix, form a given string
+type suffixParser struct {
+	// maps the suffix of every known type to the type
+	suffixes map[string][]string
+}
+
+func newSuffixParser(typeNames []string) *suffixParser {
+	p := &suffixParser{suffixes: map[string][]string{}}
+	for _, t := range typeNames {
+		// the suffix of a pointer to a simple type is not "P" followed by the suffix of the type
+		for _, typ := range []string{t, "*" + t} {
+			s := concrete.Suffix(typ)
+			p.suffixes[s] = append(p.suffixes[s], typ)
+		}
+	}
+	return p
+}
+
+// parse returns all possibilities to split s into n types
+func (p *suffixParser) parse(s string, n int) [][]string {
+	if n == 0 {
+		if s == "" {
+			return [][]string{nil}
+		}
+		return nil
+	}
+	var result [][]string
+	for _, m := range p.types(s) {
+		for _, rest := range p.parse(m.rest, n-1) {
+			result = append(result, append([]string{m.typ}, rest...))
+		}
+	}
+	return result
+}
+
+// typeMatch is a type found at the beginning of a string
+type typeMatch struct {
+	typ  string
+	rest string
+}
+
+// types returns all types whose suffix is a prefix of s
+func (p *suffixParser) types(s string) []typeMatch {
+	var result []typeMatch
+	for suffix, types := range p.suffixes {
+		if strings.HasPrefix(s, suffix) {
+			for _, t := range types {
+				result = append(result, typeMatch{t, s[len(suffix):]})
+			}
+		}
+	}
+
+	elem := func(prefix string, create func(string) string) {
+		if strings.HasPrefix(s, prefix) {
+			for _, m := range p.types(s[len(prefix):]) {
+				result = append(result, typeMatch{create(m.typ), m.rest})
+			}
+		}
+	}
+	elem("Slice", func(t string) string { return "[]" + t })
+	elem("P", func(t string) string { return "*" + t })
+	elem("Chan", func(t string) string { return "chan " + t })
+	elem("SendChan", func(t string) string { return "chan<- " + t })
+	elem("RecvChan", func(t string) string { return "<-chan " + t })
+	if strings.HasPrefix(s, "Map") {
+		for _, k := range p.types(s[3:]) {
+			for _, v := range p.types(k.rest) {
+				result = append(result, typeMatch{"map[" + k.typ + "]" + v.typ, v.rest})
+			}
+		}
+	}
+	if strings.HasPrefix(s, "Array") {
+		l := strings.IndexFunc(s[5:], func(r rune) bool { return !unicode.IsDigit(r) })
+		if l > 0 {
+			length := s[5 : 5+l]
+			elem("Array"+length, func(t string) string { return "[" + length + "]" + t })
+		}
+	}
+
+	// only types which create the suffix are valid, e.g. *[]int creates PSliceInt but *int creates Pint
+	var valid []typeMatch
+	for _, m := range result {
+		if s == concrete.Suffix(m.typ)+m.rest && !containsMatch(valid, m) {
+			valid = append(valid, m)
+		}
+	}
+	sort.Slice(valid, func(i, j int) bool {
+		if valid[i].typ != valid[j].typ {
+			return valid[i].typ < valid[j].typ
+		}
+		return valid[i].rest < valid[j].rest
+	})
+	return valid
+}
+
+func containsMatch(list []typeMatch, m typeMatch) bool {
+	for _, l := range list {
+		if l == m {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package infer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var universe = []string{"bool", "byte", "int", "int32", "int64", "string", "float64", "error"}
+
+var mmap = Template{
+	GenericTypes: []string{"KEY", "VALUE"},
+	Renamed: map[string][]int{
+		"KeyMagic": {0},
+		"Map":      {0, 1},
+		"New":      {0, 1},
+	},
+}
+
+func TestInstances(t *testing.T) {
+	data := []struct {
+		name      string
+		undefined []string
+		gen       string
+	}{
+		{name: "simple", undefined: []string{"NewStringInt64"}, gen: "string,int64"},
+		{name: "ignore", undefined: []string{"foo", "NewStringInt64", "Bar"}, gen: "string,int64"},
+		{name: "dedup", undefined: []string{"NewStringInt64", "MapStringInt64", "MapStringString"}, gen: "string,int64;string,string"},
+		{name: "partial", undefined: []string{"KeyMagicString", "MapStringInt"}, gen: "string,int"},
+		{name: "pointer", undefined: []string{"MapPintPUser"}, gen: "*int,*User"},
+		{name: "slice", undefined: []string{"MapSliceByteSlicePint"}, gen: "[]byte,[]*int"},
+		{name: "map", undefined: []string{"NewMapStringBoolInt"}, gen: "map[string]bool,int"},
+		{name: "chan", undefined: []string{"NewChanIntRecvChanError"}, gen: "chan int,<-chan error"},
+		{name: "array", undefined: []string{"NewArray4IntPSliceUser"}, gen: "[4]int,*[]User"},
+		{name: "package", undefined: []string{"NewStringModelsUser"}, gen: "string,models.User"},
+	}
+	for _, d := range data {
+		gen, err := Instances(mmap, d.undefined, append(universe, "User", "models.User"))
+		if assert.NoError(t, err, d.name) {
+			assert.Equal(t, d.gen, gen, d.name)
+		}
+	}
+}
+
+func TestInstancesErrors(t *testing.T) {
+	data := []struct {
+		name      string
+		t         Template
+		undefined []string
+		types     []string
+		err       string
+	}{
+		{name: "nothing", t: mmap, undefined: []string{"Foo"}, err: "no undefined identifier matches"},
+		{name: "partial", t: mmap, undefined: []string{"KeyMagicString"}, err: "KeyMagicString depends on KEY only"},
+		{name: "uncovered", t: mmap, undefined: []string{"KeyMagicInt", "MapStringInt"}, err: "KeyMagicInt depends on KEY only"},
+		{
+			name: "ambiguous",
+			t: Template{
+				GenericTypes: []string{"A"},
+				Renamed:      map[string][]int{"New": {0}, "NewInt": {0}},
+			},
+			undefined: []string{"NewIntInt"},
+			types:     []string{"Int", "IntInt"},
+			err:       "the meaning of NewIntInt is ambiguous: NewInt[Int] or New[IntInt]",
+		},
+	}
+	for _, d := range data {
+		types := d.types
+		if types == nil {
+			types = universe
+		}
+		_, err := Instances(d.t, d.undefined, types)
+		if assert.Error(t, err, d.name) {
+			assert.Contains(t, err.Error(), d.err, d.name)
+		}
+	}
+}
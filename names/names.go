@@ -1,45 +1,72 @@
 package names
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"go/build"
+	"io"
 	"os"
 	"path"
 	"path/filepath"
+	"regexp"
+	"strings"
 )
 
-func createOutNameInt(out, tem string) string {
+// OutName returns the name of the output file. If out is empty, the name is derived from
+// the template: A template in the current directory gets the prefix "gen-", otherwise the
+// name of the template is used.
+func OutName(out, tem string) string {
 	if out != "" {
 		return out
 	}
 
 	name := path.Base(tem)
-	if name == tem {
+	if !strings.HasSuffix(name, ".go") {
+		// the template is a directory
+		name += ".go"
+	}
+	if path.Base(tem) == tem {
 		return "gen-" + name
 	}
 
 	return name
 }
 
-func CreateOutName(out, tem, message string) (string, error) {
-	name := createOutNameInt(out, tem)
+// LegacyHeader is the header which was written by former versions of yagi
+const LegacyHeader = "// generated by yagi. Don't modify this file!\n// Any changes will be lost if this file is regenerated.\n\n"
 
-	if _, err := os.Stat(name); err == nil {
-		// file exists, open it
-		f, err := os.Open(name)
-		if err != nil {
-			return "", fmt.Errorf("can not open file %v, got error: %v", name, err)
-		}
-		defer f.Close()
+// the first line of a file generated by yagi, following the Go convention
+var generatedLine = regexp.MustCompile(`^// Code generated by yagi.* DO NOT EDIT\.$`)
+
+// Header creates the header of a generated file. It follows the Go convention for
+// generated code and records the template, the concrete types and the yagi version.
+func Header(tem, gen, version string) string {
+	return fmt.Sprintf("// Code generated by yagi %s from %s with -gen=%s. DO NOT EDIT.\n\n", version, filepath.ToSlash(filepath.Clean(tem)), strings.TrimSpace(gen))
+}
 
-		// file read the file header
-		header := make([]byte, len(message))
-		_, err = f.Read(header)
+// the version in the first line of a file generated by yagi
+var headerVersion = regexp.MustCompile(`^// Code generated by yagi \S+ from `)
+
+// WithoutVersion removes the yagi version from the header of a generated file.
+// The version depends on the build of yagi, so it is ignored when checking
+// if a generated file is up to date.
+func WithoutVersion(source []byte) []byte {
+	return headerVersion.ReplaceAll(source, []byte("// Code generated by yagi from "))
+}
+
+// CreateOutName creates the name of the output file. If the output file already
+// exists, it is checked that this file was created by yagi.
+func CreateOutName(out, tem string) (string, error) {
+	name := OutName(out, tem)
+
+	if _, err := os.Stat(name); err == nil {
+		// file exists, check the header
+		generated, err := IsGenerated(name)
 		if err != nil {
-			return "", fmt.Errorf("can not read from file %v, got error: %v", name, err)
+			return "", err
 		}
-
-		if string(header) != message {
+		if !generated {
 			return "", errors.New("can not overwrite file " + name + ": It seems not to be created by yagi!")
 		}
 	}
@@ -47,6 +74,32 @@ func CreateOutName(out, tem, message string) (string, error) {
 	return name, nil
 }
 
+// IsGenerated checks if the given file was created by yagi. This is the case if it
+// starts with the header created by Header or with the legacy header.
+func IsGenerated(name string) (bool, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return false, fmt.Errorf("can not open file %v, got error: %v", name, err)
+	}
+	defer f.Close()
+
+	// read the file header
+	header := make([]byte, 4096)
+	n, err := io.ReadFull(f, header)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false, fmt.Errorf("can not read from file %v, got error: %v", name, err)
+	}
+	header = header[:n]
+
+	if bytes.HasPrefix(header, []byte(LegacyHeader)) {
+		return true, nil
+	}
+	if p := bytes.IndexByte(header, '\n'); p >= 0 {
+		return generatedLine.Match(bytes.TrimSuffix(header[:p], []byte("\r"))), nil
+	}
+	return false, nil
+}
+
 func GetPackageName(pac, out string) (string, error) {
 	if pac != "" {
 		return pac, nil
@@ -59,3 +112,56 @@ func GetPackageName(pac, out string) (string, error) {
 
 	return path.Base(path.Dir(abs)), nil
 }
+
+// GetPackagePath returns the import path of the package the given file belongs to.
+// The import path is derived from the module path found in the next go.mod file
+// or from the GOPATH. If this is not possible, an empty string is returned.
+func GetPackagePath(out string) string {
+	abs, err := filepath.Abs(out)
+	if err != nil {
+		return ""
+	}
+	dir := filepath.Dir(abs)
+
+	for d := dir; ; d = filepath.Dir(d) {
+		if mod, err := os.ReadFile(filepath.Join(d, "go.mod")); err == nil {
+			modulePath := getModulePath(mod)
+			if modulePath == "" {
+				return ""
+			}
+			return joinPath(modulePath, d, dir)
+		}
+		if filepath.Dir(d) == d {
+			break
+		}
+	}
+
+	for _, gopath := range filepath.SplitList(build.Default.GOPATH) {
+		src := filepath.Join(gopath, "src")
+		if rel, err := filepath.Rel(src, dir); err == nil && !strings.HasPrefix(rel, "..") {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return ""
+}
+
+func getModulePath(mod []byte) string {
+	for _, line := range strings.Split(string(mod), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "module" {
+			return strings.Trim(fields[1], "\"`")
+		}
+	}
+	return ""
+}
+
+func joinPath(modulePath, moduleDir, dir string) string {
+	rel, err := filepath.Rel(moduleDir, dir)
+	if err != nil {
+		return ""
+	}
+	if rel == "." {
+		return modulePath
+	}
+	return modulePath + "/" + filepath.ToSlash(rel)
+}
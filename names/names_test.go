@@ -2,6 +2,9 @@ package names
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -14,10 +17,68 @@ func TestCreateNames(t *testing.T) {
 		{out: "", tem: "list.go", exp: "gen-list.go"},
 		{out: "", tem: "./list/list.go", exp: "list.go"},
 		{out: "z.go", tem: "./list/list.go", exp: "z.go"},
+		{out: "", tem: "./list", exp: "list.go"},
+		{out: "", tem: "list", exp: "gen-list.go"},
 	}
 
 	for _, d := range data {
-		res := createOutNameInt(d.out, d.tem)
+		res := OutName(d.out, d.tem)
 		assert.Equal(t, d.exp, res, "checked %v, expected '%v', got '%v'", fmt.Sprint(d), d.exp, res)
 	}
 }
+
+func TestPackagePath(t *testing.T) {
+	dir, err := os.MkdirTemp("", "yagi")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "app", "models"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "app", "go.mod"), []byte("module github.com/acme/app\n\ngo 1.12\n"), 0644))
+
+	assert.Equal(t, "github.com/acme/app", GetPackagePath(filepath.Join(dir, "app", "gen.go")))
+	assert.Equal(t, "github.com/acme/app/models", GetPackagePath(filepath.Join(dir, "app", "models", "gen.go")))
+}
+
+func TestIsGenerated(t *testing.T) {
+	dir, err := os.MkdirTemp("", "yagi")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	data := []struct {
+		name, content string
+		generated     bool
+	}{
+		{name: "gen.go", content: Header("temp/list.go", "int64;int32", "v1.0.0") + "package a\n", generated: true},
+		{name: "legacy.go", content: LegacyHeader + "package a\n", generated: true},
+		{name: "other.go", content: "// Code generated by stringer. DO NOT EDIT.\n\npackage a\n", generated: false},
+		{name: "man.go", content: "package a\n\nvar a int\n", generated: false},
+		{name: "short.go", content: "package a", generated: false},
+	}
+	for _, d := range data {
+		name := filepath.Join(dir, d.name)
+		assert.NoError(t, os.WriteFile(name, []byte(d.content), 0644))
+		generated, err := IsGenerated(name)
+		assert.NoError(t, err)
+		assert.Equal(t, d.generated, generated, d.name)
+	}
+
+	_, err = IsGenerated(filepath.Join(dir, "missing.go"))
+	assert.Error(t, err)
+}
+
+func TestHeader(t *testing.T) {
+	header := Header("temp/list.go", "int64;int32", "v1.0.0")
+	assert.Equal(t, "// Code generated by yagi v1.0.0 from temp/list.go with -gen=int64;int32. DO NOT EDIT.\n\n", header)
+	assert.Regexp(t, `^// Code generated .* DO NOT EDIT\.$`, strings.TrimSpace(header))
+}
+
+func TestWithoutVersion(t *testing.T) {
+	a := []byte(Header("temp/list.go", "int64", "devel") + "package a\n")
+	b := []byte(Header("temp/list.go", "int64", "v1.2.0") + "package a\n")
+	assert.Equal(t, string(WithoutVersion(a)), string(WithoutVersion(b)))
+	assert.Equal(t, "// Code generated by yagi from temp/list.go with -gen=int64. DO NOT EDIT.\n\npackage a\n", string(WithoutVersion(a)))
+
+	// only the header is changed
+	c := []byte("package a\n\n// Code generated by yagi v1 from x\n")
+	assert.Equal(t, string(c), string(WithoutVersion(c)))
+}
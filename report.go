@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/scanner"
+	"io"
+	"strings"
+)
+
+// the maximum number of positioned errors which are reported as text
+const maxErrors = 10
+
+// report writes the error to w. If the error contains a list of positioned
+// errors, every error is written in its own line, prefixed by file:line:col.
+// The context added by wrapping the list, like the generation of a config
+// file, is written in front of every error.
+func report(w io.Writer, err error) {
+	var list scanner.ErrorList
+	if !errors.As(err, &list) || len(list) == 0 {
+		fmt.Fprintln(w, err)
+		return
+	}
+	context := errorContext(err, list)
+	for i, e := range list {
+		if i == maxErrors {
+			fmt.Fprintf(w, "too many errors, %d more\n", len(list)-i)
+			break
+		}
+		fmt.Fprintln(w, context+e.Error())
+	}
+}
+
+// errorContext returns the text which was added in front of the list by wrapping it
+func errorContext(err error, list scanner.ErrorList) string {
+	return strings.TrimSuffix(err.Error(), list.Error())
+}
+
+// diagnostic is the JSON representation of an error
+type diagnostic struct {
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+// reportJSON writes the error to w as a JSON array of diagnostics.
+// Errors without a position only contain the message.
+func reportJSON(w io.Writer, err error) {
+	var diagnostics []diagnostic
+	var list scanner.ErrorList
+	if errors.As(err, &list) && len(list) > 0 {
+		context := errorContext(err, list)
+		for _, e := range list {
+			diagnostics = append(diagnostics, diagnostic{
+				File:    e.Pos.Filename,
+				Line:    e.Pos.Line,
+				Column:  e.Pos.Column,
+				Message: context + e.Msg,
+			})
+		}
+	} else {
+		diagnostics = append(diagnostics, diagnostic{Message: err.Error()})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.Encode(diagnostics)
+}
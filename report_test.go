@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func positionedError() error {
+	var list scanner.ErrorList
+	list.Add(token.Position{Filename: "temp/list.go", Line: 8, Column: 5}, "a < b is invalid")
+	list.Add(token.Position{Filename: "temp/list.go", Line: 12, Column: 1}, "unused")
+	return fmt.Errorf("reading source file: %w", list)
+}
+
+func TestReport(t *testing.T) {
+	var buf bytes.Buffer
+	report(&buf, positionedError())
+	assert.Equal(t, "reading source file: temp/list.go:8:5: a < b is invalid\nreading source file: temp/list.go:12:1: unused\n", buf.String())
+
+	// the context of the config file is kept
+	buf.Reset()
+	report(&buf, fmt.Errorf("%v: generation %d: %w", "yagi.yaml", 2, errors.Unwrap(positionedError())))
+	assert.Equal(t, "yagi.yaml: generation 2: temp/list.go:8:5: a < b is invalid\nyagi.yaml: generation 2: temp/list.go:12:1: unused\n", buf.String())
+
+	buf.Reset()
+	report(&buf, errors.New("no generic types found"))
+	assert.Equal(t, "no generic types found\n", buf.String())
+}
+
+func TestReportJSON(t *testing.T) {
+	var buf bytes.Buffer
+	reportJSON(&buf, positionedError())
+	assert.Equal(t, `[{"file":"temp/list.go","line":8,"column":5,"message":"reading source file: a < b is invalid"},{"file":"temp/list.go","line":12,"column":1,"message":"reading source file: unused"}]`+"\n", buf.String())
+
+	buf.Reset()
+	reportJSON(&buf, errors.New("no generic types found"))
+	assert.Equal(t, `[{"message":"no generic types found"}]`+"\n", buf.String())
+}
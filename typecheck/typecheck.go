@@ -0,0 +1,284 @@
+// Package typecheck uses go/types to obtain type information about the
+// package the generated code belongs to.
+package typecheck
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hneemann/yagi/concrete"
+	"golang.org/x/tools/imports"
+)
+
+// the name of the file which is used to declare the types to resolve
+const typesFile = "yagi_types.go"
+
+// Resolver resolves concrete types in the context of the package in a directory.
+// So the concrete types can be declared in the package itself or in an imported package.
+type Resolver struct {
+	dir         string
+	exclude     string
+	packageName string
+}
+
+// NewResolver creates a new resolver for the package in the given directory.
+// The file exclude is ignored, which is usually the file that is regenerated.
+// The package name is used if the directory does not contain go files.
+func NewResolver(dir, exclude, packageName string) *Resolver {
+	return &Resolver{dir: dir, exclude: exclude, packageName: packageName}
+}
+
+// Resolve returns the type of each of the given type expressions.
+// The imports are the packages which are needed to access the types.
+// Missing imports are added the same way go imports does it.
+func (r *Resolver) Resolve(typeExprs []string, imps []concrete.Import) ([]types.Type, error) {
+	fset := token.NewFileSet()
+	files, err := parseDir(fset, r.dir, false, func(name string) bool {
+		return sameFile(name, r.exclude)
+	})
+	if err != nil {
+		return nil, err
+	}
+	packageName := r.packageName
+	if len(files) > 0 {
+		packageName = files[0].Name.Name
+	}
+
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "package %s\n\n", packageName)
+	for _, imp := range imps {
+		fmt.Fprintf(&src, "import %s %q\n", imp.Name, imp.Path)
+	}
+	for i, t := range typeExprs {
+		fmt.Fprintf(&src, "var %s %s\n", varName(i), t)
+	}
+	source, err := imports.Process(filepath.Join(r.dir, typesFile), src.Bytes(), nil)
+	if err != nil {
+		return nil, err
+	}
+	file, err := parser.ParseFile(fset, typesFile, source, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// errors in the other files are ignored because they
+	// are usually caused by the file which is to regenerate
+	var typeErrors []string
+	conf := types.Config{
+		Importer: importer.ForCompiler(fset, "source", nil),
+		Error: func(err error) {
+			if te, ok := err.(types.Error); ok && te.Fset.Position(te.Pos).Filename == typesFile {
+				typeErrors = append(typeErrors, te.Msg)
+			}
+		},
+	}
+	pkg, _ := conf.Check(packageName, fset, append(files, file), nil)
+
+	var result []types.Type
+	for i, t := range typeExprs {
+		obj := pkg.Scope().Lookup(varName(i))
+		if obj == nil || obj.Type() == types.Typ[types.Invalid] {
+			return nil, fmt.Errorf("can not resolve type %s: %s", t, strings.Join(typeErrors, "; "))
+		}
+		result = append(result, obj.Type())
+	}
+	return result, nil
+}
+
+func varName(i int) string {
+	return fmt.Sprintf("_yagiType%d", i)
+}
+
+// Check type checks the generated files together with the other files of the package
+// in the directory dir. The map holds the source of each generated file. The test files
+// of the package are only included if there are generated test files.
+// Only the errors found in the generated files are returned. Their positions
+// have to be obtained by PositionFor without adjusting them by //line directives.
+func Check(dir string, generated map[string][]byte) ([]types.Error, error) {
+	fset := token.NewFileSet()
+	var files []*ast.File
+	tests := false
+	var names []string
+	for name := range generated {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		file, err := parser.ParseFile(fset, name, generated[name], 0)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+		tests = tests || strings.HasSuffix(name, "_test.go")
+	}
+	if len(files) == 0 {
+		return nil, nil
+	}
+	packageName := files[0].Name.Name
+
+	siblings, err := parseDir(fset, dir, tests, func(name string) bool {
+		for g := range generated {
+			if sameFile(name, g) {
+				return true
+			}
+		}
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+	// the siblings are checked first, so a conflicting declaration is reported in the generated code
+	var all []*ast.File
+	for _, f := range siblings {
+		// the external test package is not needed
+		if f.Name.Name == packageName {
+			all = append(all, f)
+		}
+	}
+	files = append(all, files...)
+
+	var typeErrors []types.Error
+	conf := types.Config{
+		Importer: importer.ForCompiler(fset, "source", nil),
+		Error: func(err error) {
+			// continuation errors starting with a tab only add details to the previous error
+			if te, ok := err.(types.Error); ok && !strings.HasPrefix(te.Msg, "\t") {
+				if _, ok := generated[te.Fset.PositionFor(te.Pos, false).Filename]; ok {
+					typeErrors = append(typeErrors, te)
+				}
+			}
+		},
+	}
+	conf.Check(packageName, fset, files, nil)
+	return typeErrors, nil
+}
+
+var undefined = regexp.MustCompile(`^undefined: ([\pL_][\pL\pN_]*)$`)
+
+// Undefined type checks the package in the directory dir, including its test files, but without
+// the excluded files. It returns the identifiers which are used but not declared, in the order of
+// their first use. It also returns the names of all types which can be used as concrete types in the
+// package: the predeclared types, the types declared in the package and the exported types of the
+// imported packages.
+func Undefined(dir string, exclude ...string) ([]string, []string, error) {
+	fset := token.NewFileSet()
+	files, err := parseDir(fset, dir, true, func(name string) bool {
+		for _, e := range exclude {
+			if sameFile(name, e) {
+				return true
+			}
+		}
+		return false
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(files) == 0 {
+		return nil, nil, fmt.Errorf("no go files found in %v", dir)
+	}
+	packageName := files[0].Name.Name
+	for _, f := range files {
+		if !strings.HasSuffix(f.Name.Name, "_test") {
+			packageName = f.Name.Name
+			break
+		}
+	}
+	var pkgFiles []*ast.File
+	for _, f := range files {
+		// the external test package is not needed
+		if f.Name.Name == packageName {
+			pkgFiles = append(pkgFiles, f)
+		}
+	}
+
+	var names []string
+	found := map[string]bool{}
+	conf := types.Config{
+		Importer: importer.ForCompiler(fset, "source", nil),
+		Error: func(err error) {
+			if te, ok := err.(types.Error); ok {
+				if m := undefined.FindStringSubmatch(te.Msg); m != nil && !found[m[1]] {
+					found[m[1]] = true
+					names = append(names, m[1])
+				}
+			}
+		},
+	}
+	pkg, _ := conf.Check(packageName, fset, pkgFiles, nil)
+
+	var typeNames []string
+	for _, name := range types.Universe.Names() {
+		if _, ok := types.Universe.Lookup(name).(*types.TypeName); ok && name != "comparable" {
+			typeNames = append(typeNames, name)
+		}
+	}
+	typeNames = append(typeNames, scopeTypes(pkg.Scope(), "", false)...)
+	for _, imp := range pkg.Imports() {
+		typeNames = append(typeNames, scopeTypes(imp.Scope(), imp.Name()+".", true)...)
+	}
+	return names, typeNames, nil
+}
+
+// scopeTypes returns the names of the types in the scope with the given prefix
+func scopeTypes(scope *types.Scope, prefix string, exported bool) []string {
+	var typeNames []string
+	for _, name := range scope.Names() {
+		if tn, ok := scope.Lookup(name).(*types.TypeName); ok && (!exported || tn.Exported()) {
+			typeNames = append(typeNames, prefix+name)
+		}
+	}
+	return typeNames
+}
+
+// parseDir parses the go files in the given directory. Test files are only
+// included if tests is set. Files excluded by build constraints and all files for
+// which exclude returns true are ignored.
+func parseDir(fset *token.FileSet, dir string, tests bool, exclude func(name string) bool) ([]*ast.File, error) {
+	names, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+
+	var files []*ast.File
+	for _, name := range names {
+		if !tests && strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		if exclude(name) {
+			continue
+		}
+		match, err := build.Default.MatchFile(dir, filepath.Base(name))
+		if err != nil {
+			return nil, err
+		}
+		if !match {
+			continue
+		}
+		file, err := parser.ParseFile(fset, name, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// sameFile returns true if both names refer to the same file
+func sameFile(a, b string) bool {
+	if b == "" {
+		return false
+	}
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
@@ -0,0 +1,108 @@
+package typecheck
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hneemann/yagi/concrete"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolve(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "user.go"), []byte(`package models
+
+type User struct {
+	Name string
+}
+
+func (u User) String() string {
+	return u.Name
+}
+
+var list ListUser
+`), 0644)
+	assert.NoError(t, err)
+
+	r := NewResolver(dir, filepath.Join(dir, "gen.go"), "models")
+	types, err := r.Resolve([]string{"fmt.Stringer", "User", "*User", "[]int"}, nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 4, len(types))
+		assert.Equal(t, "fmt.Stringer", types[0].String())
+		assert.Equal(t, "models.User", types[1].String())
+		assert.Equal(t, "*models.User", types[2].String())
+		assert.Equal(t, "[]int", types[3].String())
+	}
+
+	_, err = r.Resolve([]string{"Unknown"}, nil)
+	assert.Error(t, err)
+}
+
+func TestResolveIgnoresBuildConstraints(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "user.go"), []byte("package models\n\ntype User struct{}\n"), 0644)
+	assert.NoError(t, err)
+	err = os.WriteFile(filepath.Join(dir, "gen_tool.go"), []byte("//go:build ignore\n\npackage main\n\nfunc main() {}\n"), 0644)
+	assert.NoError(t, err)
+
+	r := NewResolver(dir, filepath.Join(dir, "gen.go"), "models")
+	types, err := r.Resolve([]string{"User"}, nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "models.User", types[0].String())
+	}
+
+	typeErrors, err := Check(dir, map[string][]byte{filepath.Join(dir, "gen.go"): []byte("package models\n\nvar u User\n")})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(typeErrors))
+
+	undefined, _, err := Undefined(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(undefined))
+}
+
+func TestResolveImport(t *testing.T) {
+	r := NewResolver(t.TempDir(), "", "main")
+	types, err := r.Resolve([]string{"b.Buffer"}, []concrete.Import{{Name: "b", Path: "bytes"}})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "bytes.Buffer", types[0].String())
+	}
+}
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "user.go"), []byte(`package models
+
+type User struct{}
+
+var broken int = "sibling errors are ignored"
+`), 0644)
+	assert.NoError(t, err)
+
+	gen := filepath.Join(dir, "gen.go")
+	typeErrors, err := Check(dir, map[string][]byte{gen: []byte(`package models
+
+import "fmt"
+
+func Print(u User) {
+	fmt.Println(u)
+}
+`)})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(typeErrors))
+
+	typeErrors, err = Check(dir, map[string][]byte{gen: []byte(`package models
+
+func Less(a, b User) bool {
+	return a < b
+}
+
+type User int
+`)})
+	assert.NoError(t, err)
+	if assert.Equal(t, 2, len(typeErrors)) {
+		assert.Equal(t, gen, typeErrors[0].Fset.Position(typeErrors[0].Pos).Filename)
+		assert.Contains(t, typeErrors[0].Msg, "User redeclared")
+		assert.Contains(t, typeErrors[1].Msg, "a < b")
+	}
+}
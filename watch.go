@@ -0,0 +1,131 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hneemann/yagi/config"
+	"github.com/hneemann/yagi/names"
+)
+
+// watcher polls a set of files and calls a function whenever one of them has changed.
+// Polling is used instead of file system notifications because it works the same on
+// all platforms and does not miss the changes of editors which replace the file.
+type watcher struct {
+	// files returns the files to watch, it is called on every poll
+	// so the set of files can change, e.g. if the config is modified
+	files func() []string
+	// interval is the time between two polls
+	interval time.Duration
+	// debounce is the time the files have to stay unchanged before the function is called,
+	// so an editor writing several files or writing a file in several steps triggers only one run
+	debounce time.Duration
+}
+
+// fileState is the state of a watched file
+type fileState struct {
+	exists  bool
+	modTime time.Time
+	size    int64
+}
+
+// snapshot holds the states of all watched files
+type snapshot map[string]fileState
+
+func takeSnapshot(files []string) snapshot {
+	s := snapshot{}
+	for _, f := range files {
+		info, err := os.Stat(f)
+		if err != nil {
+			s[f] = fileState{}
+			continue
+		}
+		s[f] = fileState{exists: true, modTime: info.ModTime(), size: info.Size()}
+	}
+	return s
+}
+
+func (s snapshot) equal(other snapshot) bool {
+	if len(s) != len(other) {
+		return false
+	}
+	for f, state := range s {
+		o, ok := other[f]
+		if !ok || o.exists != state.exists || o.size != state.size || !o.modTime.Equal(state.modTime) {
+			return false
+		}
+	}
+	return true
+}
+
+// run calls f once and then every time the watched files have changed, until stop is closed.
+func (w watcher) run(stop <-chan struct{}, f func()) {
+	last := takeSnapshot(w.files())
+	f()
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	var changed time.Time
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			s := takeSnapshot(w.files())
+			if !s.equal(last) {
+				last = s
+				changed = time.Now()
+				continue
+			}
+			if !changed.IsZero() && time.Since(changed) >= w.debounce {
+				changed = time.Time{}
+				f()
+			}
+		}
+	}
+}
+
+// watchedFiles returns a function which returns the files used by the generation: the
+// config file and the files of the templates, or the files of the template given by tem.
+func watchedFiles(tem, configFile string) func() []string {
+	return func() []string {
+		if configFile == "" {
+			return templateFiles(tem)
+		}
+		files := []string{configFile}
+		m, err := config.Read(configFile)
+		if err != nil {
+			// the config file is watched until the error is fixed
+			return files
+		}
+		for _, g := range m.Generate {
+			files = append(files, templateFiles(filepath.Join(filepath.Dir(configFile), g.Template))...)
+		}
+		return files
+	}
+}
+
+// templateFiles returns the files of a template, including the test files
+func templateFiles(tem string) []string {
+	info, err := os.Stat(tem)
+	if err != nil || !info.IsDir() {
+		return []string{tem, strings.TrimSuffix(tem, ".go") + "_test.go"}
+	}
+	fileNames, err := filepath.Glob(filepath.Join(tem, "*.go"))
+	if err != nil {
+		return []string{tem}
+	}
+	// the outputs of a generation into the template directory are not watched
+	var files []string
+	for _, name := range fileNames {
+		if generated, err := names.IsGenerated(name); err != nil || !generated {
+			files = append(files, name)
+		}
+	}
+	// new files are detected because the list of files changes
+	sort.Strings(files)
+	return files
+}
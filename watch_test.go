@@ -0,0 +1,72 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshot(t *testing.T) {
+	dir, err := os.MkdirTemp("", "yagi")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "tem.go")
+	files := []string{name}
+	missing := takeSnapshot(files)
+	assert.True(t, missing.equal(takeSnapshot(files)))
+
+	assert.NoError(t, os.WriteFile(name, []byte("package tem\n"), 0644))
+	created := takeSnapshot(files)
+	assert.False(t, missing.equal(created))
+	assert.True(t, created.equal(takeSnapshot(files)))
+
+	assert.NoError(t, os.WriteFile(name, []byte("package tem\n\n"), 0644))
+	assert.False(t, created.equal(takeSnapshot(files)))
+	assert.False(t, created.equal(takeSnapshot(append(files, filepath.Join(dir, "new.go")))))
+}
+
+func TestWatcher(t *testing.T) {
+	dir, err := os.MkdirTemp("", "yagi")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "tem.go")
+	assert.NoError(t, os.WriteFile(name, []byte("package tem\n"), 0644))
+
+	runs := make(chan struct{}, 10)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	w := watcher{files: func() []string { return []string{name} }, interval: 5 * time.Millisecond, debounce: 50 * time.Millisecond}
+	go func() {
+		w.run(stop, func() { runs <- struct{}{} })
+		close(done)
+	}()
+
+	waitRun := func() bool {
+		select {
+		case <-runs:
+			return true
+		case <-time.After(2 * time.Second):
+			return false
+		}
+	}
+
+	// the initial run
+	assert.True(t, waitRun())
+
+	// several quick changes cause a single run
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, os.WriteFile(name, []byte("package tem\n"+string(make([]byte, i+1))), 0644))
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.True(t, waitRun())
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, 0, len(runs))
+
+	close(stop)
+	<-done
+}
@@ -8,90 +8,349 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
-	"go/parser"
+	"go/scanner"
 	"go/token"
+	"io"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
-	"github.com/hneemann/yagi/concrete"
-	"github.com/hneemann/yagi/generify"
+	"github.com/hneemann/yagi/config"
+	"github.com/hneemann/yagi/diff"
+	"github.com/hneemann/yagi/discover"
+	"github.com/hneemann/yagi/generator"
 	"github.com/hneemann/yagi/names"
-	"golang.org/x/tools/imports"
 )
 
-const message = "// generated by yagi. Don't modify this file!\n// Any changes will be lost if this file is regenerated.\n\n"
-
 func main() {
-	tem := flag.String("tem", "", "name of the template go file")
-	out := flag.String("out", "", "name of the new source file")
+	tem := flag.String("tem", "", "name of the template go file or of the template package directory, - reads the template from stdin")
+	out := flag.String("out", "", "name of the new source file, - writes the code to stdout")
 	pac := flag.String("pac", "", "package name in the created file")
-	gen := flag.String("gen", "", "concrete types e.g string,int;string,double64")
-	imp := flag.Bool("imp", true, "run go imports")
+	gen := flag.String("gen", "", "concrete types e.g string,int;string,double64 or Name=string,int")
+	var opt options
+	flag.BoolVar(&opt.imp, "imp", true, "run go imports")
+	split := flag.Bool("split", false, "create an output file for each file of a template package")
+	tests := flag.Bool("tests", false, "also create the tests from the test files of the template")
+	flag.BoolVar(&opt.typeCheck, "typecheck", true, "type check the generated code before writing it")
+	flag.BoolVar(&opt.line, "line", false, "write //line directives which map the generated code back to the template")
+	flag.BoolVar(&opt.check, "check", false, "only check that the output files are up to date, show a diff if not")
+	jsonOutput := flag.Bool("json", false, "report errors as JSON diagnostics")
+	configFile := flag.String("config", "", "config file (.yaml or .json) describing several generations")
+	flag.BoolVar(&opt.auto, "auto", false, "infer the concrete types from the undefined identifiers in the package of the output file")
+	flag.BoolVar(&opt.explain, "explain", false, "explain the dependency analysis of the template without writing the output")
+	watch := flag.Bool("watch", false, "watch the template and the config file and regenerate the outputs on every change")
 	flag.Parse()
 
-	// read the source file
-	fset := token.NewFileSet()
-	ast, err := parser.ParseFile(fset, *tem, nil, parser.ParseComments)
-	if err != nil {
-		fmt.Println("reading source file: ", err)
-		return
+	printError := func(err error) {
+		if *jsonOutput {
+			reportJSON(os.Stderr, err)
+		} else {
+			report(os.Stderr, err)
+		}
 	}
 
-	// prepeare the concrete types
-	c, err := concrete.New(*gen)
-	if err != nil {
-		fmt.Println("processing concrete types: ", err)
+	generate := func() error {
+		if opt.auto && (flag.NArg() > 0 || *configFile != "") {
+			return fmt.Errorf("the -auto flag can only be used together with -tem")
+		}
+		if flag.NArg() > 0 {
+			if *tem != "" || *configFile != "" {
+				return fmt.Errorf("directories can not be used together with -tem or -config")
+			}
+			return runDiscovery(flag.Args(), opt)
+		}
+		if *configFile != "" {
+			return runConfig(generator.NewTemplates(), *configFile, opt)
+		}
+		return run(generator.NewTemplates(), "", *tem, *out, *pac, *gen, *split, *tests, opt)
+	}
+
+	if *watch {
+		if flag.NArg() > 0 || *tem == stdio {
+			printError(fmt.Errorf("the -watch flag can not be used together with directories or -tem=-"))
+			os.Exit(1)
+		}
+		w := watcher{files: watchedFiles(*tem, *configFile), interval: 250 * time.Millisecond, debounce: 200 * time.Millisecond}
+		w.run(nil, func() {
+			err := generate()
+			if err != nil {
+				printError(err)
+			}
+			fmt.Println("waiting for changes")
+		})
 		return
 	}
 
-	// create output name
-	outName, err := names.CreateOutName(*out, *tem, message)
+	err := generate()
 	if err != nil {
-		fmt.Println(err)
-		return
+		printError(err)
+		os.Exit(1)
 	}
+}
+
+// options holds the options which control how the output files are created
+type options struct {
+	// run go imports
+	imp bool
+	// type check the generated code
+	typeCheck bool
+	// write //line directives
+	line bool
+	// do not write the output but compare it to the existing files
+	check bool
+	// infer the concrete types from the undefined identifiers
+	auto bool
+	// print the dependency analysis instead of writing the output
+	explain bool
+}
 
-	packageName, err := names.GetPackageName(*pac, outName)
+// runConfig creates all generations described in the config file. All
+// paths in the config file are relative to the directory of the config file.
+func runConfig(t *generator.Templates, configFile string, opt options) error {
+	m, err := config.Read(configFile)
 	if err != nil {
-		fmt.Println(err)
-		return
+		return err
+	}
+
+	dir := filepath.Dir(configFile)
+	for i, g := range m.Generate {
+		o := opt
+		o.line = o.line || g.Line
+		if g.Imports != nil {
+			o.imp = *g.Imports
+		}
+		if g.TypeCheck != nil {
+			o.typeCheck = *g.TypeCheck
+		}
+		err = run(t, dir, g.Template, g.Out, g.Package, g.Types(), g.Split, g.Tests, o)
+		if err != nil {
+			return fmt.Errorf("%v: generation %d: %w", configFile, i+1, err)
+		}
 	}
+	return nil
+}
 
-	// generify the source file
-	gener := generify.New(ast, c)
-	var buffer = new(bytes.Buffer)
-	buffer.WriteString(message)
-	err = gener.Do(packageName, buffer)
+// runDiscovery creates all generations requested by the //yagi:instantiate
+// directives found in the given directories. Generations using different
+// templates are created in parallel.
+func runDiscovery(patterns []string, opt options) error {
+	requests, err := discover.Find(patterns)
 	if err != nil {
-		fmt.Println(err)
-		return
+		return err
+	}
+	if len(requests) == 0 {
+		return fmt.Errorf("no //yagi:instantiate directives found")
+	}
+
+	// the template ast is modified during generation, so
+	// all requests using the same template are processed in order
+	var order []string
+	groups := map[string][]discover.Request{}
+	for _, r := range requests {
+		key, err := filepath.Abs(filepath.Join(r.Dir, r.Template))
+		if err != nil {
+			return err
+		}
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], r)
+	}
+
+	errs := make([]error, len(order))
+	var wg sync.WaitGroup
+	for i, key := range order {
+		wg.Add(1)
+		go func(i int, requests []discover.Request) {
+			defer wg.Done()
+			t := generator.NewTemplates()
+			for _, r := range requests {
+				o := opt
+				o.line = o.line || r.Line
+				err := run(t, r.Dir, r.Template, r.Out, r.Package, r.Gen, r.Split, r.Tests, o)
+				if err != nil {
+					errs[i] = fmt.Errorf("%v: %w", r.Pos, err)
+					return
+				}
+			}
+		}(i, groups[key])
+	}
+	wg.Wait()
+
+	var list scanner.ErrorList
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		var positioned scanner.ErrorList
+		if errors.As(err, &positioned) {
+			// the position of the directive is kept in front of every message
+			context := errorContext(err, positioned)
+			for _, e := range positioned {
+				list.Add(e.Pos, context+e.Msg)
+			}
+		} else {
+			list.Add(token.Position{}, err.Error())
+		}
+	}
+	return list.Err()
+}
+
+// stdio is the file name which stands for stdin or stdout
+const stdio = "-"
+
+// run creates the output files of a generation. The paths are
+// relative to the directory dir, which is the current directory if empty.
+func run(t *generator.Templates, dir, tem, out, pac, gen string, split, tests bool, opt options) error {
+	if opt.auto && gen != "" {
+		return fmt.Errorf("the -gen flag can not be used together with -auto")
+	}
+	if split && opt.auto {
+		return fmt.Errorf("the -split flag can not be used together with -auto")
+	}
+	if split && out != "" {
+		return fmt.Errorf("the -out flag can not be used together with -split")
 	}
 
-	var output []byte
-	if *imp {
-		// run go imports
-		output, err = imports.Process("", buffer.Bytes(), nil)
+	template := generator.Template{Path: tem}
+	if tem == stdio {
+		if out == "" {
+			return fmt.Errorf("the -out flag is required if the template is read from stdin")
+		}
+		source, err := io.ReadAll(os.Stdin)
 		if err != nil {
-			fmt.Println("go imports has an error, try -imp=false: ", err)
-			return
+			return fmt.Errorf("reading the template from stdin: %w", err)
 		}
-	} else {
-		output = buffer.Bytes()
+		template.Source = source
 	}
 
-	// write the output
-	file, err := os.Create(outName)
+	var explain io.Writer
+	if opt.explain {
+		explain = os.Stdout
+	}
+
+	toStdout := out == stdio
+	if toStdout {
+		if tests || opt.check {
+			return fmt.Errorf("the -tests and -check flags can not be used together with -out=-")
+		}
+		// the output name is only used to find the package of the output
+		out = ""
+		if tem == stdio {
+			out = "gen.go"
+		}
+	}
+
+	result, err := generator.Generate(context.Background(), generator.Options{
+		Template:         template,
+		Gen:              gen,
+		Auto:             opt.auto,
+		Dir:              dir,
+		Out:              out,
+		Package:          pac,
+		Tests:            tests,
+		Split:            split,
+		LineDirectives:   opt.line,
+		NoImports:        !opt.imp,
+		NoTypeCheck:      !opt.typeCheck,
+		NoOverwriteCheck: toStdout,
+		Templates:        t,
+		Explain:          explain,
+	})
 	if err != nil {
-		fmt.Println("error creating output file: ", err)
-		return
+		return err
+	}
+	if len(result.Diagnostics) > 0 {
+		return result.Diagnostics
+	}
+	if opt.explain {
+		return nil
+	}
+	if toStdout {
+		_, err = os.Stdout.Write(result.Files[0].Source)
+		return err
+	}
+	return writeOutputs(result.Files, opt)
+}
+
+// writeOutputs writes the output files. In check mode the
+// output is compared to the existing files instead of writing it.
+func writeOutputs(files []generator.File, opt options) error {
+	if opt.check {
+		return checkOutputs(files)
+	}
+
+	for _, f := range files {
+		err := writeOutput(f.Name, f.Source)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkOutputs compares the generated code with the existing output files
+// and prints a unified diff for every file which is not up to date. The yagi
+// version in the headers is ignored, so the files do not depend on the build of yagi.
+func checkOutputs(files []generator.File) error {
+	var stale []string
+	for _, f := range files {
+		existing, err := os.ReadFile(f.Name)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("error reading output file: %v", err)
+		}
+		if !bytes.Equal(names.WithoutVersion(existing), names.WithoutVersion(f.Source)) {
+			name := filepath.ToSlash(f.Name)
+			fmt.Print(diff.Unified("a/"+name, "b/"+name, existing, f.Source))
+			stale = append(stale, f.Name)
+		}
 	}
-	defer file.Close()
+	if len(stale) > 0 {
+		return fmt.Errorf("not up to date: %s", strings.Join(stale, ", "))
+	}
+	return nil
+}
 
+// writeOutput writes the output file. If the file already has the given content,
+// it is not touched, so its modification time is kept and the build cache and file
+// watchers are not invalidated. Otherwise the content is written to a temporary
+// file in the same directory, which then replaces the output file, so the output
+// file is never left truncated or half written.
+func writeOutput(outName string, output []byte) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(outName); err == nil {
+		mode = info.Mode().Perm()
+		existing, err := os.ReadFile(outName)
+		if err == nil && bytes.Equal(existing, output) {
+			return nil
+		}
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(outName), "."+filepath.Base(outName)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("error creating output file: %v", err)
+	}
+	tmpName := file.Name()
 	_, err = file.Write(output)
+	if err == nil {
+		err = file.Chmod(mode)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpName, outName)
+	}
 	if err != nil {
-		fmt.Println("error writing output file: ", err)
-		return
+		os.Remove(tmpName)
+		return fmt.Errorf("error writing output file: %v", err)
 	}
 	fmt.Println("generated ", outName)
+	return nil
 }
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hneemann/yagi/generator"
+	"github.com/hneemann/yagi/names"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteOutput(t *testing.T) {
+	dir, err := os.MkdirTemp("", "yagi")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "list.go")
+	assert.NoError(t, writeOutput(name, []byte("package list\n")))
+	info, err := os.Stat(name)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+
+	// an unchanged output is not touched
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	assert.NoError(t, os.Chtimes(name, old, old))
+	assert.NoError(t, writeOutput(name, []byte("package list\n")))
+	info, err = os.Stat(name)
+	assert.NoError(t, err)
+	assert.True(t, info.ModTime().Equal(old))
+
+	// a changed output is replaced, the mode is kept
+	assert.NoError(t, os.Chmod(name, 0600))
+	assert.NoError(t, writeOutput(name, []byte("package list\n\n// changed\n")))
+	data, err := os.ReadFile(name)
+	assert.NoError(t, err)
+	assert.Equal(t, "package list\n\n// changed\n", string(data))
+	info, err = os.Stat(name)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// no temporary files are left
+	files, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(files))
+}
+
+func TestCheckOutputs(t *testing.T) {
+	dir, err := os.MkdirTemp("", "yagi")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "list.go")
+	files := []generator.File{{Name: name, Source: []byte("package list\n")}}
+	assert.Error(t, checkOutputs(files))
+
+	assert.NoError(t, os.WriteFile(name, []byte("package list\n"), 0644))
+	assert.NoError(t, checkOutputs(files))
+
+	// the version of yagi in the header is ignored
+	files[0].Source = []byte(names.Header("list/list.go", "int", "v1.0.0") + "package list\n")
+	assert.NoError(t, os.WriteFile(name, []byte(names.Header("list/list.go", "int", "devel")+"package list\n"), 0644))
+	assert.NoError(t, checkOutputs(files))
+	files[0].Source = []byte("package list\n")
+
+	// a missing newline at the end of the file is a difference
+	assert.NoError(t, os.WriteFile(name, []byte("package list"), 0644))
+	assert.Error(t, checkOutputs(files))
+}